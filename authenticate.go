@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elfingit/gin-utils/middleware/auth"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrUnknownAuthenticator = errors.New("unknown authenticator")
+
+func (s *TransportServer) checkAuth(requirement *AuthRequirement) error {
+	if requirement == nil {
+		return nil
+	}
+	if len(requirement.Authenticators) == 0 {
+		return fmt.Errorf("%w: none named", ErrUnknownAuthenticator)
+	}
+
+	for _, name := range requirement.Authenticators {
+		if _, ok := s.cfg.authenticators[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAuthenticator, name)
+		}
+	}
+
+	return nil
+}
+
+// authenticate tries the authenticators of requirement in order. When none succeeds the
+// request gets 401 with the challenge of each, unless it is optional and carries no
+// credentials at all. When an authenticator could not check the credentials, e.g. because
+// its key store is down, the request gets 503 without challenges instead.
+func (s *TransportServer) authenticate(requirement AuthRequirement) gin.HandlerFunc {
+	authenticators := make([]auth.Authenticator, 0, len(requirement.Authenticators))
+	for _, name := range requirement.Authenticators {
+		authenticators = append(authenticators, s.cfg.authenticators[name])
+	}
+
+	return func(c *gin.Context) {
+		challenges := make([]string, 0, len(authenticators))
+		rejected := false
+		var unavailable error
+
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(c.Request)
+			if err == nil {
+				auth.SetPrincipal(c, principal)
+				c.Next()
+				return
+			}
+			if errors.Is(err, auth.ErrUnavailable) {
+				unavailable = err
+				continue
+			}
+
+			rejected = rejected || !errors.Is(err, auth.ErrNoCredentials)
+			challenges = append(challenges, authenticator.Challenge(err))
+		}
+
+		if unavailable != nil {
+			auth.Unavailable(c, unavailable)
+			return
+		}
+		if requirement.Optional && !rejected {
+			c.Next()
+			return
+		}
+
+		auth.Unauthorized(c, challenges...)
+	}
+}
@@ -0,0 +1,281 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elfingit/gin-utils/middleware/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// headerAuthenticator accepts requests whose header holds "valid".
+type headerAuthenticator struct {
+	scheme string
+	header string
+}
+
+func (a headerAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	switch r.Header.Get(a.header) {
+	case "":
+		return nil, auth.ErrNoCredentials
+	case "valid":
+		return &auth.Principal{Scheme: a.scheme, Subject: a.scheme + "-user"}, nil
+	case "down":
+		return nil, fmt.Errorf("%w: dial tcp db-primary.internal:5432", auth.ErrUnavailable)
+	}
+
+	return nil, errors.New("rejected")
+}
+
+func (a headerAuthenticator) Challenge(err error) string {
+	if errors.Is(err, auth.ErrNoCredentials) {
+		return a.scheme
+	}
+
+	return a.scheme + ` error="rejected"`
+}
+
+func authHandler() *mockHandler {
+	whoami := func(c *gin.Context) {
+		subject := "anonymous"
+		if principal := auth.GetPrincipal(c); principal != nil {
+			subject = principal.Subject
+		}
+		c.String(http.StatusOK, subject)
+	}
+
+	return &mockHandler{
+		routes: []Route{
+			{
+				Uri:     "/orders",
+				Method:  http.MethodGet,
+				Handler: whoami,
+				Auth:    &AuthRequirement{Authenticators: []string{"jwt", "apikey"}},
+			},
+			{
+				Uri:     "/catalog",
+				Method:  http.MethodGet,
+				Handler: whoami,
+				Auth:    &AuthRequirement{Authenticators: []string{"jwt"}, Optional: true},
+			},
+			{
+				Uri:  "/admin",
+				Auth: &AuthRequirement{Authenticators: []string{"apikey"}},
+				Routes: []Route{
+					{Uri: "/stats", Method: http.MethodGet, Handler: whoami, IsAuthProtected: true},
+				},
+			},
+		},
+	}
+}
+
+func TestTransportServerRouteAuth(t *testing.T) {
+	server := newTestServer(t, authHandler(),
+		WithAuthenticator("jwt", headerAuthenticator{scheme: "Bearer", header: "X-Token"}),
+		WithAuthenticator("apikey", headerAuthenticator{scheme: "ApiKey", header: "X-API-Key"}),
+	)
+
+	tests := []struct {
+		name               string
+		path               string
+		headers            map[string]string
+		expectedStatus     int
+		expectedBody       string
+		expectedChallenges []string
+	}{
+		{
+			name:           "first authenticator",
+			path:           "/api/v1/orders",
+			headers:        map[string]string{"X-Token": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Bearer-user",
+		},
+		{
+			name:           "second authenticator",
+			path:           "/api/v1/orders",
+			headers:        map[string]string{"X-API-Key": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ApiKey-user",
+		},
+		{
+			name:           "first failure does not stop the second authenticator",
+			path:           "/api/v1/orders",
+			headers:        map[string]string{"X-Token": "expired", "X-API-Key": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ApiKey-user",
+		},
+		{
+			name:               "no credentials",
+			path:               "/api/v1/orders",
+			expectedStatus:     http.StatusUnauthorized,
+			expectedChallenges: []string{"Bearer", "ApiKey"},
+		},
+		{
+			name:               "rejected credentials",
+			path:               "/api/v1/orders",
+			headers:            map[string]string{"X-Token": "expired"},
+			expectedStatus:     http.StatusUnauthorized,
+			expectedChallenges: []string{`Bearer error="rejected"`, "ApiKey"},
+		},
+		{
+			name:           "unavailable authenticator",
+			path:           "/api/v1/orders",
+			headers:        map[string]string{"X-Token": "down"},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "unavailable authenticator does not stop the second one",
+			path:           "/api/v1/orders",
+			headers:        map[string]string{"X-Token": "down", "X-API-Key": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ApiKey-user",
+		},
+		{
+			name:           "optional with unavailable authenticator",
+			path:           "/api/v1/catalog",
+			headers:        map[string]string{"X-Token": "down"},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "optional without credentials",
+			path:           "/api/v1/catalog",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "anonymous",
+		},
+		{
+			name:           "optional with credentials",
+			path:           "/api/v1/catalog",
+			headers:        map[string]string{"X-Token": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Bearer-user",
+		},
+		{
+			name:               "optional with rejected credentials",
+			path:               "/api/v1/catalog",
+			headers:            map[string]string{"X-Token": "expired"},
+			expectedStatus:     http.StatusUnauthorized,
+			expectedChallenges: []string{`Bearer error="rejected"`},
+		},
+		{
+			name:           "inherited from the group",
+			path:           "/api/v1/admin/stats",
+			headers:        map[string]string{"X-API-Key": "valid"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ApiKey-user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRequest(server, http.MethodGet, tt.path, tt.headers)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "db-primary") {
+				t.Errorf("expected the failure not to be sent, got %s", w.Body.String())
+			}
+
+			challenges := w.Header().Values("WWW-Authenticate")
+			if len(challenges) != len(tt.expectedChallenges) {
+				t.Fatalf("expected challenges %q, got %q", tt.expectedChallenges, challenges)
+			}
+			for i := range challenges {
+				if challenges[i] != tt.expectedChallenges[i] {
+					t.Errorf("expected challenges %q, got %q", tt.expectedChallenges, challenges)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportServerRouteAuthReplacesAuthMiddleware(t *testing.T) {
+	authCalled, permissionCalled := false, false
+
+	server := newTestServer(t, authHandler(),
+		WithAuthenticator("jwt", headerAuthenticator{scheme: "Bearer", header: "X-Token"}),
+		WithAuthenticator("apikey", headerAuthenticator{scheme: "ApiKey", header: "X-API-Key"}),
+		WithAuthMiddleware(func(c *gin.Context) {
+			authCalled = true
+			c.Next()
+		}),
+		WithPermissionMiddleware(func(c *gin.Context) {
+			permissionCalled = true
+			c.Next()
+		}),
+	)
+
+	w := serveRequest(server, http.MethodGet, "/api/v1/admin/stats", map[string]string{"X-API-Key": "valid"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if authCalled {
+		t.Error("expected Route.Auth to replace the auth middleware")
+	}
+	if !permissionCalled {
+		t.Error("expected the permission middleware to run for an auth protected route")
+	}
+}
+
+func TestTransportServerRouteAuthUnknownAuthenticator(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST), WithAuthenticator("jwt", headerAuthenticator{}))
+
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{Uri: "/a", Method: http.MethodGet, Handler: func(c *gin.Context) {}, Auth: &AuthRequirement{Authenticators: []string{"jwt", "saml"}}},
+			{Uri: "/b", Method: http.MethodGet, Handler: func(c *gin.Context) {}, Auth: &AuthRequirement{}},
+		},
+	})
+	if !errors.Is(err, ErrUnknownAuthenticator) {
+		t.Fatalf("expected ErrUnknownAuthenticator, got %v", err)
+	}
+	if len(server.engine.Routes()) != 0 {
+		t.Errorf("expected no routes to be mounted, got %d", len(server.engine.Routes()))
+	}
+}
+
+func TestTransportServerRouteAuthHMACVersionNegotiation(t *testing.T) {
+	secret := []byte("shared-secret")
+	hmacAuth, err := auth.NewHMAC(auth.HMACConfig{
+		Keys:   auth.StaticHMACKeyStore{"k1": {Secret: secret, Subject: "billing-service"}},
+		Nonces: auth.NewMemoryNonceStore(),
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	server := newTestServer(t, &mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/orders",
+				Method: http.MethodPost,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, auth.GetPrincipal(c).Subject)
+				},
+				Auth: &AuthRequirement{Authenticators: []string{"hmac"}},
+			},
+		},
+	}, WithVersionNegotiation("acme"), WithAuthenticator("hmac", hmacAuth))
+
+	for _, path := range []string{"/api/v1/orders", "/api/orders"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"id":1}`))
+			if err := auth.SignRequest(req, "k1", secret); err != nil {
+				t.Fatalf("failed to sign request: %v", err)
+			}
+			server.engine.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || w.Body.String() != "billing-service" {
+				t.Errorf("expected the signed request to authenticate, got %d %q", w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultCertReloadInterval = 10 * time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// CertWatcher serves a key pair from disk and swaps it in place when the files change,
+// so rotated certificates are picked up without restarting the server.
+type CertWatcher struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	onError  func(err error)
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	certStamp fileStamp
+	keyStamp  fileStamp
+}
+
+type CertWatcherOption func(*CertWatcher)
+
+func WithCertReloadInterval(interval time.Duration) CertWatcherOption {
+	return func(w *CertWatcher) {
+		w.interval = interval
+	}
+}
+
+func WithCertReloadErrorHandler(handler func(err error)) CertWatcherOption {
+	return func(w *CertWatcher) {
+		w.onError = handler
+	}
+}
+
+func NewCertWatcher(certFile, keyFile string, opts ...CertWatcherOption) (*CertWatcher, error) {
+	w := &CertWatcher{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: defaultCertReloadInterval,
+		onError:  func(err error) {},
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.interval <= 0 {
+		return nil, fmt.Errorf("certificate reload interval must be positive, got %s", w.interval)
+	}
+
+	certStamp, keyStamp := w.stamps()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	w.cert = &cert
+	w.certStamp = certStamp
+	w.keyStamp = keyStamp
+
+	return w, nil
+}
+
+// Reload reads the key pair from disk. On failure the previously loaded certificate
+// keeps being served and the error is both returned and passed to the error handler.
+func (w *CertWatcher) Reload() error {
+	certStamp, keyStamp := w.stamps()
+
+	cert, err := tls.LoadX509KeyPair(w.certFile, w.keyFile)
+
+	w.mu.Lock()
+	w.certStamp = certStamp
+	w.keyStamp = keyStamp
+	if err == nil {
+		w.cert = &cert
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		err = fmt.Errorf("reload TLS key pair: %w", err)
+		w.onError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (w *CertWatcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.cert, nil
+}
+
+// Watch polls the certificate and key files and reloads them whenever either changes.
+// It blocks until ctx is cancelled.
+func (w *CertWatcher) Watch(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.changed() {
+				_ = w.Reload()
+			}
+		}
+	}
+}
+
+func (w *CertWatcher) changed() bool {
+	certStamp, keyStamp := w.stamps()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return certStamp != w.certStamp || keyStamp != w.keyStamp
+}
+
+func (w *CertWatcher) stamps() (fileStamp, fileStamp) {
+	return statFile(w.certFile), statFile(w.keyFile)
+}
+
+func statFile(name string) fileStamp {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func servedSerial(t *testing.T, w *CertWatcher) string {
+	t.Helper()
+
+	cert, err := w.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cert == nil || cert.Leaf == nil {
+		t.Fatal("expected parsed certificate to be served")
+	}
+
+	return cert.Leaf.SerialNumber.String()
+}
+
+func TestNewCertWatcher(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := newTestServerCert(t, ca).writeFiles(t, t.TempDir())
+
+	t.Run("loads initial key pair", func(t *testing.T) {
+		w, err := NewCertWatcher(certFile, keyFile)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if w.interval != defaultCertReloadInterval {
+			t.Errorf("expected default interval %s, got %s", defaultCertReloadInterval, w.interval)
+		}
+		servedSerial(t, w)
+	})
+
+	t.Run("fails on missing files", func(t *testing.T) {
+		if _, err := NewCertWatcher("missing.pem", "missing.key"); err == nil {
+			t.Error("expected error for missing key pair")
+		}
+	})
+
+	t.Run("rejects non-positive interval", func(t *testing.T) {
+		if _, err := NewCertWatcher(certFile, keyFile, WithCertReloadInterval(0)); err == nil {
+			t.Error("expected error for zero interval")
+		}
+	})
+}
+
+func TestCertWatcherReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	first := newTestServerCert(t, ca)
+	certFile, keyFile := first.writeFiles(t, dir)
+
+	var reported []error
+	w, err := NewCertWatcher(certFile, keyFile, WithCertReloadErrorHandler(func(err error) {
+		reported = append(reported, err)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("picks up rotated key pair", func(t *testing.T) {
+		second := newTestServerCert(t, ca)
+		second.writeFiles(t, dir)
+
+		if err := w.Reload(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := servedSerial(t, w); got != second.cert.SerialNumber.String() {
+			t.Errorf("expected serial %s, got %s", second.cert.SerialNumber, got)
+		}
+	})
+
+	t.Run("keeps serving old certificate on parse failure", func(t *testing.T) {
+		before := servedSerial(t, w)
+
+		if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+			t.Fatalf("failed to corrupt certificate: %v", err)
+		}
+
+		if err := w.Reload(); err == nil {
+			t.Fatal("expected reload error")
+		}
+		if got := servedSerial(t, w); got != before {
+			t.Errorf("expected serial %s to be kept, got %s", before, got)
+		}
+		if len(reported) != 1 {
+			t.Errorf("expected 1 reported error, got %d", len(reported))
+		}
+	})
+}
+
+func TestCertWatcherWatch(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := newTestServerCert(t, ca).writeFiles(t, dir)
+
+	var mu sync.Mutex
+	var reported []error
+	w, err := NewCertWatcher(certFile, keyFile,
+		WithCertReloadInterval(10*time.Millisecond),
+		WithCertReloadErrorHandler(func(err error) {
+			mu.Lock()
+			reported = append(reported, err)
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx)
+		close(done)
+	}()
+
+	rotated := newTestServerCert(t, ca)
+	rotated.writeFiles(t, dir)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for servedSerial(t, w) != rotated.cert.SerialNumber.String() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected watcher to pick up rotated certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Watch to return after cancellation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 0 {
+		t.Errorf("expected no reload errors, got %v", reported)
+	}
+}
+
+func TestTransportServerCertWatcher(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	first := newTestServerCert(t, ca)
+	certFile, keyFile := first.writeFiles(t, dir)
+
+	w, err := NewCertWatcher(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithCertWatcher(w),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	peerSerial := func() string {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS12})
+		if err != nil {
+			t.Fatalf("expected handshake to succeed, got %v", err)
+		}
+		defer conn.Close()
+
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}
+
+	if got := peerSerial(); got != first.cert.SerialNumber.String() {
+		t.Errorf("expected serial %s, got %s", first.cert.SerialNumber, got)
+	}
+
+	second := newTestServerCert(t, ca)
+	second.writeFiles(t, dir)
+	if err := w.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got := peerSerial(); got != second.cert.SerialNumber.String() {
+		t.Errorf("expected serial %s after reload, got %s", second.cert.SerialNumber, got)
+	}
+}
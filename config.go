@@ -1,6 +1,20 @@
 package http
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/elfingit/gin-utils/middleware/auth"
+	"github.com/elfingit/gin-utils/middleware/cors"
+	"github.com/gin-gonic/gin"
+)
 
 const (
 	MODE_PROD = "prod"
@@ -15,6 +29,36 @@ type cfg struct {
 	authMiddleware       func(c *gin.Context)
 	permissionMiddleware func(c *gin.Context)
 	corsMiddleware       func(c *gin.Context)
+	corsPolicy           *cors.Policy
+	authenticators       map[string]auth.Authenticator
+	tlsCertFile          string
+	tlsKeyFile           string
+	tlsConfig            *tls.Config
+	clientCAs            *x509.CertPool
+	certWatcher          *CertWatcher
+	http3                bool
+	h2c                  bool
+	listener             net.Listener
+	systemdListener      bool
+	unixSocket           string
+	unixSocketMode       os.FileMode
+	shutdownTimeout      time.Duration
+	readTimeout          time.Duration
+	readHeaderTimeout    time.Duration
+	writeTimeout         time.Duration
+	idleTimeout          time.Duration
+	maxHeaderBytes       int
+	baseContext          func(l net.Listener) context.Context
+	connContext          func(ctx context.Context, conn net.Conn) context.Context
+	errorLog             *log.Logger
+	upgradePath          string
+	upgradeArgs          []string
+	basePath             string
+	versionAuth          map[string]func(c *gin.Context)
+	versionPermission    map[string]func(c *gin.Context)
+	versionVendor        string
+	routesEndpoint       string
+	maintenanceAllowlist []string
 }
 
 type Option func(*cfg)
@@ -49,8 +93,292 @@ func WithPermissionMiddleware(middleware func(c *gin.Context)) Option {
 	}
 }
 
+// WithBasePath mounts the API versions under path instead of /api.
+func WithBasePath(path string) Option {
+	return func(c *cfg) {
+		c.basePath = path
+	}
+}
+
+// WithVersionAuthMiddleware replaces the auth middleware for routes registered under version.
+func WithVersionAuthMiddleware(version string, middleware func(c *gin.Context)) Option {
+	return func(c *cfg) {
+		if c.versionAuth == nil {
+			c.versionAuth = make(map[string]func(c *gin.Context))
+		}
+		c.versionAuth[version] = middleware
+	}
+}
+
+// WithVersionPermissionMiddleware replaces the permission middleware for routes registered under version.
+func WithVersionPermissionMiddleware(version string, middleware func(c *gin.Context)) Option {
+	return func(c *cfg) {
+		if c.versionPermission == nil {
+			c.versionPermission = make(map[string]func(c *gin.Context))
+		}
+		c.versionPermission[version] = middleware
+	}
+}
+
+// WithVersionNegotiation serves requests to unversioned paths, e.g. /api/users, from the version
+// named by an Accept media type like application/vnd.<vendor>.v2+json or an X-API-Version header,
+// defaulting to v1. A version that is not registered gets 406 Not Acceptable.
+func WithVersionNegotiation(vendor string) Option {
+	return func(c *cfg) {
+		c.versionVendor = vendor
+	}
+}
+
+// WithRoutesEndpoint serves the registered routes as JSON on path, in dev mode only.
+func WithRoutesEndpoint(path string) Option {
+	return func(c *cfg) {
+		c.routesEndpoint = path
+	}
+}
+
+// WithMaintenanceAllowlist keeps the routes selected by name or path pattern, e.g. health
+// checks, available while maintenance mode is on.
+func WithMaintenanceAllowlist(namesOrPatterns ...string) Option {
+	return func(c *cfg) {
+		c.maintenanceAllowlist = append(c.maintenanceAllowlist, namesOrPatterns...)
+	}
+}
+
+func (c *cfg) versionMiddlewares(version string) (auth, permission func(c *gin.Context)) {
+	auth, permission = c.authMiddleware, c.permissionMiddleware
+
+	if middleware, ok := c.versionAuth[version]; ok {
+		auth = middleware
+	}
+	if middleware, ok := c.versionPermission[version]; ok {
+		permission = middleware
+	}
+
+	return auth, permission
+}
+
+// WithAuthenticator registers authenticator under name for routes that list it in Route.Auth.
+func WithAuthenticator(name string, authenticator auth.Authenticator) Option {
+	return func(c *cfg) {
+		if c.authenticators == nil {
+			c.authenticators = make(map[string]auth.Authenticator)
+		}
+		c.authenticators[name] = authenticator
+	}
+}
+
+// WithCorsMiddleware replaces the CORS handling of routes without their own Route.CORS policy.
 func WithCorsMiddleware(middleware func(c *gin.Context)) Option {
 	return func(c *cfg) {
 		c.corsMiddleware = middleware
 	}
 }
+
+// WithCorsPolicy sets the CORS policy of routes without their own Route.CORS policy. Unlike
+// WithCorsMiddleware, preflights only allow the methods registered for the requested path.
+func WithCorsPolicy(policy *cors.Policy) Option {
+	return func(c *cfg) {
+		c.corsPolicy = policy
+		c.corsMiddleware = nil
+	}
+}
+
+func WithTLS(certFile, keyFile string) Option {
+	return func(c *cfg) {
+		c.tlsCertFile = certFile
+		c.tlsKeyFile = keyFile
+	}
+}
+
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *cfg) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithMutualTLS requires every client to present a certificate signed by one of clientCAs.
+func WithMutualTLS(clientCAs *x509.CertPool) Option {
+	return func(c *cfg) {
+		c.clientCAs = clientCAs
+	}
+}
+
+// WithCertWatcher serves certificates from w and keeps it watching for rotated files while the server runs.
+// It cannot be combined with WithTLS or a WithTLSConfig that has Certificates.
+func WithCertWatcher(w *CertWatcher) Option {
+	return func(c *cfg) {
+		c.certWatcher = w
+	}
+}
+
+// WithHTTP3 additionally serves HTTP/3 over QUIC on the same port. It requires TLS.
+func WithHTTP3() Option {
+	return func(c *cfg) {
+		c.http3 = true
+	}
+}
+
+// WithH2C serves cleartext HTTP/2, both with prior knowledge and via the HTTP/1.1 Upgrade header.
+// It cannot be combined with TLS.
+func WithH2C() Option {
+	return func(c *cfg) {
+		c.h2c = true
+	}
+}
+
+// WithUnixSocket serves on a Unix domain socket instead of host:port. A stale socket
+// left by a previous process is removed; a non-zero mode is applied to the socket file.
+func WithUnixSocket(path string, mode os.FileMode) Option {
+	return func(c *cfg) {
+		c.unixSocket = path
+		c.unixSocketMode = mode
+	}
+}
+
+func WithListener(l net.Listener) Option {
+	return func(c *cfg) {
+		c.listener = l
+	}
+}
+
+// WithSystemdListener serves on the first socket passed by systemd socket activation.
+func WithSystemdListener() Option {
+	return func(c *cfg) {
+		c.systemdListener = true
+	}
+}
+
+// WithShutdownTimeout sets the grace period Run gives in-flight requests and shutdown hooks.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.shutdownTimeout = timeout
+	}
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.readTimeout = timeout
+	}
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.readHeaderTimeout = timeout
+	}
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.writeTimeout = timeout
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.idleTimeout = timeout
+	}
+}
+
+func WithMaxHeaderBytes(size int) Option {
+	return func(c *cfg) {
+		c.maxHeaderBytes = size
+	}
+}
+
+// WithBaseContext sets the base context of every request served from l, see http.Server.BaseContext.
+func WithBaseContext(baseContext func(l net.Listener) context.Context) Option {
+	return func(c *cfg) {
+		c.baseContext = baseContext
+	}
+}
+
+// WithConnContext derives the context used for requests on a new connection, see http.Server.ConnContext.
+func WithConnContext(connContext func(ctx context.Context, conn net.Conn) context.Context) Option {
+	return func(c *cfg) {
+		c.connContext = connContext
+	}
+}
+
+// WithErrorLog routes errors from accepting connections and from handlers to logger.
+func WithErrorLog(logger *log.Logger) Option {
+	return func(c *cfg) {
+		c.errorLog = logger
+	}
+}
+
+func (c *cfg) validate() error {
+	var errs []error
+
+	switch c.mode {
+	case MODE_PROD, MODE_DEV, MODE_TEST:
+	default:
+		errs = append(errs, fmt.Errorf("unknown mode %q, expected one of %q, %q, %q", c.mode, MODE_PROD, MODE_DEV, MODE_TEST))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read timeout", c.readTimeout},
+		{"read header timeout", c.readHeaderTimeout},
+		{"write timeout", c.writeTimeout},
+		{"idle timeout", c.idleTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.name, d.value))
+		}
+	}
+
+	if c.readTimeout > 0 && c.readHeaderTimeout > c.readTimeout {
+		errs = append(errs, fmt.Errorf("read header timeout %s exceeds read timeout %s", c.readHeaderTimeout, c.readTimeout))
+	}
+
+	if c.shutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout must be positive, got %s", c.shutdownTimeout))
+	}
+
+	if c.maxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max header bytes must not be negative, got %d", c.maxHeaderBytes))
+	}
+
+	if (c.tlsCertFile == "") != (c.tlsKeyFile == "") {
+		errs = append(errs, errors.New("TLS certificate and key files must be set together"))
+	}
+
+	if c.certWatcher != nil && (c.tlsCertFile != "" || (c.tlsConfig != nil && len(c.tlsConfig.Certificates) > 0)) {
+		errs = append(errs, ErrCertWatcherWithCertificates)
+	}
+
+	if c.h2c && c.tlsEnabled() {
+		errs = append(errs, ErrH2CWithTLS)
+	}
+
+	if c.http3 && !c.tlsEnabled() {
+		errs = append(errs, ErrHTTP3RequiresTLS)
+	}
+
+	if c.http3 && c.unixSocket != "" {
+		errs = append(errs, ErrHTTP3RequiresTCP)
+	}
+
+	sources := 0
+	for _, set := range []bool{c.listener != nil, c.systemdListener, c.unixSocket != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		errs = append(errs, errors.New("only one of WithListener, WithSystemdListener and WithUnixSocket can be used"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithUpgradeCommand sets the program Upgrade starts instead of re-executing the current binary.
+func WithUpgradeCommand(path string, args ...string) Option {
+	return func(c *cfg) {
+		c.upgradePath = path
+		c.upgradeArgs = args
+	}
+}
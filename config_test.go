@@ -1,8 +1,19 @@
 package http
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/elfingit/gin-utils/middleware/auth"
+	"github.com/elfingit/gin-utils/middleware/cors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -173,6 +184,36 @@ func TestWithCorsMiddleware(t *testing.T) {
 	}
 }
 
+func TestWithCorsPolicy(t *testing.T) {
+	policy := cors.DefaultPolicy()
+
+	c := &cfg{corsMiddleware: func(c *gin.Context) {}}
+	opt := WithCorsPolicy(policy)
+	opt(c)
+
+	if c.corsPolicy != policy {
+		t.Error("expected corsPolicy to be set")
+	}
+	if c.corsMiddleware != nil {
+		t.Error("expected corsMiddleware to be reset")
+	}
+}
+
+func TestWithAuthenticator(t *testing.T) {
+	apiKeys, err := auth.NewAPIKey(auth.APIKeyConfig{Store: auth.StaticKeyStore{}})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	c := &cfg{}
+	WithAuthenticator("apikey", apiKeys)(c)
+	WithAuthenticator("jwt", headerAuthenticator{})(c)
+
+	if c.authenticators["apikey"] != apiKeys || len(c.authenticators) != 2 {
+		t.Errorf("expected authenticators to be registered by name, got %v", c.authenticators)
+	}
+}
+
 func TestMultipleOptions(t *testing.T) {
 	expectedHost := "0.0.0.0"
 	expectedPort := uint(9000)
@@ -199,3 +240,414 @@ func TestMultipleOptions(t *testing.T) {
 		t.Errorf("expected mode %q, got %q", expectedMode, c.mode)
 	}
 }
+
+func TestWithTLS(t *testing.T) {
+	c := &cfg{}
+	opt := WithTLS("cert.pem", "key.pem")
+	opt(c)
+
+	if c.tlsCertFile != "cert.pem" {
+		t.Errorf("expected cert file %q, got %q", "cert.pem", c.tlsCertFile)
+	}
+	if c.tlsKeyFile != "key.pem" {
+		t.Errorf("expected key file %q, got %q", "key.pem", c.tlsKeyFile)
+	}
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+
+	c := &cfg{}
+	opt := WithTLSConfig(tlsConfig)
+	opt(c)
+
+	if c.tlsConfig != tlsConfig {
+		t.Error("expected tlsConfig to be set")
+	}
+}
+
+func TestWithMutualTLS(t *testing.T) {
+	pool := x509.NewCertPool()
+
+	c := &cfg{}
+	opt := WithMutualTLS(pool)
+	opt(c)
+
+	if c.clientCAs != pool {
+		t.Error("expected clientCAs to be set")
+	}
+}
+
+func TestWithCertWatcher(t *testing.T) {
+	w := &CertWatcher{}
+
+	c := &cfg{}
+	opt := WithCertWatcher(w)
+	opt(c)
+
+	if c.certWatcher != w {
+		t.Error("expected certWatcher to be set")
+	}
+}
+
+func TestWithHTTP3(t *testing.T) {
+	c := &cfg{}
+	opt := WithHTTP3()
+	opt(c)
+
+	if !c.http3 {
+		t.Error("expected http3 to be enabled")
+	}
+}
+
+func TestWithH2C(t *testing.T) {
+	c := &cfg{}
+	opt := WithH2C()
+	opt(c)
+
+	if !c.h2c {
+		t.Error("expected h2c to be enabled")
+	}
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	c := &cfg{}
+	opt := WithUnixSocket("/run/api.sock", 0o660)
+	opt(c)
+
+	if c.unixSocket != "/run/api.sock" {
+		t.Errorf("expected unix socket %q, got %q", "/run/api.sock", c.unixSocket)
+	}
+	if c.unixSocketMode != 0o660 {
+		t.Errorf("expected unix socket mode %o, got %o", 0o660, c.unixSocketMode)
+	}
+}
+
+func TestWithListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	c := &cfg{}
+	opt := WithListener(l)
+	opt(c)
+
+	if c.listener != l {
+		t.Error("expected listener to be set")
+	}
+}
+
+func TestWithSystemdListener(t *testing.T) {
+	c := &cfg{}
+	opt := WithSystemdListener()
+	opt(c)
+
+	if !c.systemdListener {
+		t.Error("expected systemdListener to be enabled")
+	}
+}
+
+func TestWithShutdownTimeout(t *testing.T) {
+	c := &cfg{}
+	opt := WithShutdownTimeout(30 * time.Second)
+	opt(c)
+
+	if c.shutdownTimeout != 30*time.Second {
+		t.Errorf("expected shutdown timeout %s, got %s", 30*time.Second, c.shutdownTimeout)
+	}
+}
+
+func TestServerTimeoutOptions(t *testing.T) {
+	c := &cfg{}
+	opts := []Option{
+		WithReadTimeout(10 * time.Second),
+		WithReadHeaderTimeout(2 * time.Second),
+		WithWriteTimeout(15 * time.Second),
+		WithIdleTimeout(time.Minute),
+		WithMaxHeaderBytes(1 << 16),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.readTimeout != 10*time.Second {
+		t.Errorf("expected read timeout %s, got %s", 10*time.Second, c.readTimeout)
+	}
+	if c.readHeaderTimeout != 2*time.Second {
+		t.Errorf("expected read header timeout %s, got %s", 2*time.Second, c.readHeaderTimeout)
+	}
+	if c.writeTimeout != 15*time.Second {
+		t.Errorf("expected write timeout %s, got %s", 15*time.Second, c.writeTimeout)
+	}
+	if c.idleTimeout != time.Minute {
+		t.Errorf("expected idle timeout %s, got %s", time.Minute, c.idleTimeout)
+	}
+	if c.maxHeaderBytes != 1<<16 {
+		t.Errorf("expected max header bytes %d, got %d", 1<<16, c.maxHeaderBytes)
+	}
+}
+
+func TestServerHookOptions(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	baseContext := func(l net.Listener) context.Context {
+		return context.Background()
+	}
+	connContext := func(ctx context.Context, conn net.Conn) context.Context {
+		return ctx
+	}
+
+	c := &cfg{}
+	opts := []Option{
+		WithBaseContext(baseContext),
+		WithConnContext(connContext),
+		WithErrorLog(logger),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.baseContext == nil {
+		t.Error("expected baseContext to be set")
+	}
+	if c.connContext == nil {
+		t.Error("expected connContext to be set")
+	}
+	if c.errorLog != logger {
+		t.Error("expected errorLog to be set")
+	}
+}
+
+func TestCfgValidate(t *testing.T) {
+	valid := func() *cfg {
+		return &cfg{
+			mode:              MODE_PROD,
+			shutdownTimeout:   time.Second,
+			readHeaderTimeout: 3 * time.Second,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "unknown mode",
+			opts:    []Option{WithMode("staging")},
+			wantMsg: `unknown mode "staging"`,
+		},
+		{
+			name:    "negative write timeout",
+			opts:    []Option{WithWriteTimeout(-time.Second)},
+			wantMsg: "write timeout must not be negative",
+		},
+		{
+			name:    "read header timeout exceeds read timeout",
+			opts:    []Option{WithReadTimeout(time.Second), WithReadHeaderTimeout(5 * time.Second)},
+			wantMsg: "read header timeout 5s exceeds read timeout 1s",
+		},
+		{
+			name: "read header timeout without read timeout",
+			opts: []Option{WithReadHeaderTimeout(5 * time.Second)},
+		},
+		{
+			name:    "zero shutdown timeout",
+			opts:    []Option{WithShutdownTimeout(0)},
+			wantMsg: "shutdown timeout must be positive",
+		},
+		{
+			name:    "negative max header bytes",
+			opts:    []Option{WithMaxHeaderBytes(-1)},
+			wantMsg: "max header bytes must not be negative",
+		},
+		{
+			name:    "certificate without key",
+			opts:    []Option{WithTLS("cert.pem", "")},
+			wantMsg: "TLS certificate and key files must be set together",
+		},
+		{
+			name:    "certificate watcher with certificate files",
+			opts:    []Option{WithCertWatcher(&CertWatcher{}), WithTLS("cert.pem", "key.pem")},
+			wantErr: ErrCertWatcherWithCertificates,
+		},
+		{
+			name:    "certificate watcher with static certificates",
+			opts:    []Option{WithCertWatcher(&CertWatcher{}), WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{{}}})},
+			wantErr: ErrCertWatcherWithCertificates,
+		},
+		{
+			name: "certificate watcher with TLS config",
+			opts: []Option{WithCertWatcher(&CertWatcher{}), WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13})},
+		},
+		{
+			name:    "h2c with TLS",
+			opts:    []Option{WithH2C(), WithTLS("cert.pem", "key.pem")},
+			wantErr: ErrH2CWithTLS,
+		},
+		{
+			name:    "HTTP/3 without TLS",
+			opts:    []Option{WithHTTP3()},
+			wantErr: ErrHTTP3RequiresTLS,
+		},
+		{
+			name:    "HTTP/3 on unix socket",
+			opts:    []Option{WithHTTP3(), WithTLS("cert.pem", "key.pem"), WithUnixSocket("/run/api.sock", 0)},
+			wantErr: ErrHTTP3RequiresTCP,
+		},
+		{
+			name:    "several listener sources",
+			opts:    []Option{WithSystemdListener(), WithUnixSocket("/run/api.sock", 0)},
+			wantMsg: "only one of WithListener, WithSystemdListener and WithUnixSocket can be used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			for _, opt := range tt.opts {
+				opt(c)
+			}
+
+			err := c.validate()
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.wantMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.wantMsg, err)
+				}
+			default:
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCfgValidateReportsEveryProblem(t *testing.T) {
+	c := &cfg{
+		mode:            "staging",
+		shutdownTimeout: time.Second,
+		idleTimeout:     -time.Second,
+		maxHeaderBytes:  -1,
+	}
+
+	err := c.validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	for _, msg := range []string{"unknown mode", "idle timeout", "max header bytes"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error to mention %q, got %v", msg, err)
+		}
+	}
+}
+
+func TestWithUpgradeCommand(t *testing.T) {
+	c := &cfg{}
+	opt := WithUpgradeCommand("/usr/local/bin/api", "--config", "/etc/api.yaml")
+	opt(c)
+
+	if c.upgradePath != "/usr/local/bin/api" {
+		t.Errorf("expected upgrade path %q, got %q", "/usr/local/bin/api", c.upgradePath)
+	}
+	if len(c.upgradeArgs) != 2 || c.upgradeArgs[1] != "/etc/api.yaml" {
+		t.Errorf("unexpected upgrade args %v", c.upgradeArgs)
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	c := &cfg{}
+	opt := WithBasePath("/internal")
+	opt(c)
+
+	if c.basePath != "/internal" {
+		t.Errorf("expected base path %q, got %q", "/internal", c.basePath)
+	}
+}
+
+func TestVersionMiddlewares(t *testing.T) {
+	var called string
+	record := func(name string) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			called = name
+		}
+	}
+
+	c := &cfg{
+		authMiddleware:       record("auth"),
+		permissionMiddleware: record("permission"),
+	}
+	WithVersionAuthMiddleware("v2", record("auth-v2"))(c)
+	WithVersionPermissionMiddleware("v3", record("permission-v3"))(c)
+
+	tests := []struct {
+		version            string
+		expectedAuth       string
+		expectedPermission string
+	}{
+		{version: "v1", expectedAuth: "auth", expectedPermission: "permission"},
+		{version: "v2", expectedAuth: "auth-v2", expectedPermission: "permission"},
+		{version: "v3", expectedAuth: "auth", expectedPermission: "permission-v3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			auth, permission := c.versionMiddlewares(tt.version)
+
+			auth(&gin.Context{})
+			if called != tt.expectedAuth {
+				t.Errorf("expected auth middleware %q, got %q", tt.expectedAuth, called)
+			}
+
+			permission(&gin.Context{})
+			if called != tt.expectedPermission {
+				t.Errorf("expected permission middleware %q, got %q", tt.expectedPermission, called)
+			}
+		})
+	}
+}
+
+func TestWithVersionNegotiation(t *testing.T) {
+	c := &cfg{}
+	opt := WithVersionNegotiation("acme")
+	opt(c)
+
+	if c.versionVendor != "acme" {
+		t.Errorf("expected vendor %q, got %q", "acme", c.versionVendor)
+	}
+}
+
+func TestWithRoutesEndpoint(t *testing.T) {
+	c := &cfg{}
+	opt := WithRoutesEndpoint("/debug/routes")
+	opt(c)
+
+	if c.routesEndpoint != "/debug/routes" {
+		t.Errorf("expected routes endpoint %q, got %q", "/debug/routes", c.routesEndpoint)
+	}
+}
+
+func TestWithMaintenanceAllowlist(t *testing.T) {
+	c := &cfg{}
+	WithMaintenanceAllowlist("health")(c)
+	WithMaintenanceAllowlist("/api/v1/status", "ready")(c)
+
+	expected := []string{"health", "/api/v1/status", "ready"}
+	if strings.Join(c.maintenanceAllowlist, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected allowlist %v, got %v", expected, c.maintenanceAllowlist)
+	}
+}
@@ -1,6 +1,12 @@
 package http
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/elfingit/gin-utils/middleware/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// MethodAny registers a route for every HTTP method.
+const MethodAny = "ANY"
 
 type Route struct {
 	Uri             string
@@ -9,8 +15,47 @@ type Route struct {
 	IsAuthProtected bool
 
 	Middlewares []gin.HandlerFunc
+
+	// Name identifies the route for TransportServer.URL. It must be unique per server;
+	// a route served under several Versions resolves to the first one.
+	Name string
+
+	// Versions lists the API versions the route is served under, e.g. {"v1", "v2"}.
+	// When empty the route belongs to the version it is registered with.
+	Versions []string
+
+	// Auth authenticates the route with authenticators registered by WithAuthenticator,
+	// instead of the auth middleware of IsAuthProtected. IsAuthProtected routes still run
+	// the permission middleware.
+	Auth *AuthRequirement
+
+	// CORS replaces the server wide CORS handling for the route, e.g. to open a public
+	// endpoint to any origin.
+	CORS *cors.Policy
+
+	// Routes turns the route into a group: its children are mounted under Uri and run
+	// Middlewares first. A group has no Method or Handler of its own; children inherit
+	// Versions, Auth and CORS unless they set their own. Children of an IsAuthProtected
+	// group are always auth protected.
+	Routes []Route
+}
+
+// AuthRequirement names the authenticators a route accepts. They are tried in order and
+// the first one to authenticate the request wins.
+type AuthRequirement struct {
+	Authenticators []string
+	// Optional lets requests without credentials through anonymously. Requests with
+	// rejected credentials still get 401 Unauthorized.
+	Optional bool
 }
 
 type Handler interface {
 	GetRoutes() []Route
 }
+
+// GroupedHandler is a Handler whose routes share a path prefix and middlewares.
+type GroupedHandler interface {
+	Handler
+	Prefix() string
+	Middlewares() []gin.HandlerFunc
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/elfingit/gin-utils/middleware/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// routeCORS keeps the registered routes so preflights can be answered per path, with the
+// policies set by Route.CORS.
+type routeCORS struct {
+	mu       sync.RWMutex
+	routes   []routeEntry
+	policies map[string]*cors.Policy
+}
+
+func (r *routeCORS) addRoutes(entries []routeEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range entries {
+		r.routes = append(r.routes, entry)
+		if entry.route.CORS == nil {
+			continue
+		}
+		if r.policies == nil {
+			r.policies = make(map[string]*cors.Policy)
+		}
+		r.policies[entry.method+" "+entry.path] = entry.route.CORS
+	}
+}
+
+func (r *routeCORS) policy(method, fullPath string) *cors.Policy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.policies[method+" "+fullPath]
+}
+
+// match returns the methods routed for path and the Route.CORS policy of the route
+// method is routed to.
+func (r *routeCORS) match(method, path string) (*cors.Policy, []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var policy *cors.Policy
+	var methods []string
+	var best string
+
+	for _, entry := range r.routes {
+		rank, ok := matchPath(entry.path, path)
+		if !ok {
+			continue
+		}
+		if !slices.Contains(methods, entry.method) {
+			methods = append(methods, entry.method)
+		}
+		if entry.method == method && (best == "" || rank < best) {
+			policy, best = entry.route.CORS, rank
+		}
+	}
+
+	return policy, methods
+}
+
+// matchPath reports whether gin routes path to pattern and ranks the match the way gin
+// picks between patterns: static segments win over parameters, parameters over catch-alls.
+func matchPath(pattern, path string) (string, bool) {
+	patterns, segments := strings.Split(pattern, "/"), strings.Split(path, "/")
+	rank := make([]byte, 0, len(patterns))
+
+	for i, segment := range patterns {
+		if i >= len(segments) {
+			return "", false
+		}
+
+		wildcard := strings.IndexAny(segment, ":*")
+		if wildcard < 0 {
+			if segments[i] != segment {
+				return "", false
+			}
+			rank = append(rank, '0')
+			continue
+		}
+
+		if !strings.HasPrefix(segments[i], segment[:wildcard]) {
+			return "", false
+		}
+		if segment[wildcard] == '*' {
+			return string(append(rank, '2')), true
+		}
+		if len(segments[i]) == wildcard {
+			return "", false
+		}
+		rank = append(rank, '1')
+	}
+
+	if len(segments) != len(patterns) {
+		return "", false
+	}
+
+	return string(rank), true
+}
+
+// corsMiddleware applies the Route.CORS policy of the matched route, falling back to
+// WithCorsMiddleware or WithCorsPolicy. Preflights are answered with the methods
+// registered for the requested path.
+func (s *TransportServer) corsMiddleware(c *gin.Context) {
+	if cors.IsPreflight(c.Request) {
+		s.preflight(c)
+		return
+	}
+
+	if c.FullPath() == "" {
+		if _, _, ok := s.unversionedPath(c); ok {
+			if _, methods := s.cors.match("", c.Request.URL.Path); len(methods) == 0 {
+				// noRoute dispatches the request to a versioned route, which answers CORS.
+				c.Next()
+				return
+			}
+		}
+	}
+
+	policy := s.cors.policy(c.Request.Method, c.FullPath())
+	if policy == nil && s.cfg.corsMiddleware != nil {
+		s.cfg.corsMiddleware(c)
+		return
+	}
+	if policy == nil {
+		policy = s.cfg.corsPolicy
+	}
+
+	if policy != nil {
+		policy.Apply(c)
+	}
+	c.Next()
+}
+
+func (s *TransportServer) preflight(c *gin.Context) {
+	policy, methods := s.cors.match(c.GetHeader("Access-Control-Request-Method"), c.Request.URL.Path)
+	if len(methods) == 0 {
+		if _, _, ok := s.unversionedPath(c); ok || s.cfg.corsMiddleware == nil {
+			c.Next()
+			return
+		}
+	}
+
+	if policy == nil && s.cfg.corsMiddleware != nil {
+		s.cfg.corsMiddleware(c)
+		return
+	}
+	if policy == nil {
+		policy = s.cfg.corsPolicy
+	}
+	if policy == nil {
+		c.Next()
+		return
+	}
+
+	policy.Preflight(c, methods)
+}
@@ -0,0 +1,211 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/elfingit/gin-utils/middleware/cors"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		rank    string
+		ok      bool
+	}{
+		{pattern: "/api/v1/users", path: "/api/v1/users", rank: "0000", ok: true},
+		{pattern: "/api/v1/users", path: "/api/v1/users/1", ok: false},
+		{pattern: "/api/v1/users/:id", path: "/api/v1/users/1", rank: "00001", ok: true},
+		{pattern: "/api/v1/users/:id", path: "/api/v1/users/", ok: false},
+		{pattern: "/api/v1/users/:id", path: "/api/v1/users", ok: false},
+		{pattern: "/api/v1/files/*path", path: "/api/v1/files/a/b", rank: "00002", ok: true},
+		{pattern: "/api/v1/files/*path", path: "/api/v1/files/", rank: "00002", ok: true},
+		{pattern: "/api/v1/users/me", path: "/api/v1/users/me", rank: "00000", ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			rank, ok := matchPath(tt.pattern, tt.path)
+			if ok != tt.ok || rank != tt.rank {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.rank, tt.ok, rank, ok)
+			}
+		})
+	}
+}
+
+func corsPolicy(t *testing.T, config cors.Config) *cors.Policy {
+	t.Helper()
+
+	policy, err := cors.NewPolicy(config)
+	if err != nil {
+		t.Fatalf("expected policy to be valid, got %v", err)
+	}
+
+	return policy
+}
+
+// corsHandler routes answer for the server wide policy unless they set a public one.
+func corsHandler(t *testing.T) *mockHandler {
+	public := corsPolicy(t, cors.Config{AllowedOrigins: []string{"*"}})
+
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+
+	return &mockHandler{
+		routes: []Route{
+			{Uri: "/status", Method: http.MethodGet, Handler: ok, CORS: public},
+			{Uri: "/users/:id", Method: http.MethodGet, Handler: ok},
+			{Uri: "/users/:id", Method: http.MethodPut, Handler: ok},
+			{Uri: "/users/me", Method: http.MethodDelete, Handler: ok, CORS: public},
+			{
+				Uri:  "/public",
+				CORS: public,
+				Routes: []Route{
+					{Uri: "/docs", Method: http.MethodGet, Handler: ok},
+				},
+			},
+		},
+	}
+}
+
+// appOnly is the server wide policy the routes of corsHandler fall back to.
+var appOnly = cors.Config{
+	AllowedOrigins:   []string{"https://app.example.com"},
+	AllowCredentials: true,
+}
+
+func TestTransportServerRouteCORS(t *testing.T) {
+	server := newTestServer(t, corsHandler(t), WithCorsPolicy(corsPolicy(t, appOnly)))
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		allow  string
+	}{
+		{name: "route policy", path: "/api/v1/status", origin: "https://other.com", allow: "*"},
+		{name: "group policy", path: "/api/v1/public/docs", origin: "https://other.com", allow: "*"},
+		{name: "server policy", path: "/api/v1/users/1", origin: "https://app.example.com", allow: "https://app.example.com"},
+		{name: "server policy rejects origin", path: "/api/v1/users/1", origin: "https://other.com", allow: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRequest(server, http.MethodGet, tt.path, map[string]string{"Origin": tt.origin})
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.allow, got)
+			}
+		})
+	}
+}
+
+func TestTransportServerRoutePreflight(t *testing.T) {
+	server := newTestServer(t, corsHandler(t), WithCorsPolicy(corsPolicy(t, appOnly)))
+
+	tests := []struct {
+		name           string
+		path           string
+		origin         string
+		method         string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{
+			name:           "methods routed for the path",
+			path:           "/api/v1/users/1",
+			origin:         "https://app.example.com",
+			method:         http.MethodPut,
+			expectedStatus: http.StatusNoContent,
+			expectedAllow:  "GET, PUT, HEAD",
+		},
+		{
+			name:           "method not routed for the path",
+			path:           "/api/v1/users/1",
+			origin:         "https://app.example.com",
+			method:         http.MethodDelete,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "static route policy wins over the parameter route",
+			path:           "/api/v1/users/me",
+			origin:         "https://other.com",
+			method:         http.MethodDelete,
+			expectedStatus: http.StatusNoContent,
+			expectedAllow:  "GET, PUT, DELETE, HEAD",
+		},
+		{
+			name:           "server policy rejects origin",
+			path:           "/api/v1/users/me",
+			origin:         "https://other.com",
+			method:         http.MethodPut,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "unknown path",
+			path:           "/api/v1/missing",
+			origin:         "https://app.example.com",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRequest(server, http.MethodOptions, tt.path, map[string]string{"Origin": tt.origin, "Access-Control-Request-Method": tt.method})
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.expectedAllow {
+				t.Errorf("expected Access-Control-Allow-Methods %q, got %q", tt.expectedAllow, got)
+			}
+		})
+	}
+}
+
+func TestTransportServerRouteCORSWithNegotiation(t *testing.T) {
+	server := newTestServer(t, corsHandler(t), WithCorsPolicy(corsPolicy(t, appOnly)), WithVersionNegotiation("acme"))
+
+	w := serveRequest(server, http.MethodOptions, "/api/status", map[string]string{"Origin": "https://other.com", "Access-Control-Request-Method": http.MethodGet})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected the route policy to answer, got %q", got)
+	}
+
+	w = serveRequest(server, http.MethodGet, "/api/status", map[string]string{"Origin": "https://other.com"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "*" {
+		t.Errorf("expected the route policy to answer once, got %v", got)
+	}
+}
+
+func TestTransportServerRouteCORSWithCustomMiddleware(t *testing.T) {
+	server := newTestServer(t, corsHandler(t), WithCorsPolicy(corsPolicy(t, appOnly)), WithCorsMiddleware(func(c *gin.Context) {
+		c.Header("X-Custom-CORS", "enabled")
+		c.Next()
+	}))
+
+	w := serveRequest(server, http.MethodGet, "/api/v1/users/1", map[string]string{"Origin": "https://app.example.com"})
+	if got := w.Header().Get("X-Custom-CORS"); got != "enabled" {
+		t.Errorf("expected the custom middleware to run, got %q", got)
+	}
+
+	w = serveRequest(server, http.MethodGet, "/api/v1/status", map[string]string{"Origin": "https://other.com"})
+	if got := w.Header().Get("X-Custom-CORS"); got != "" {
+		t.Errorf("expected the route policy to replace the custom middleware, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected Access-Control-Allow-Origin *, got %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+func gatedHandler() *mockHandler {
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+
+	return &mockHandler{
+		routes: []Route{
+			{Name: "health", Uri: "/health", Method: http.MethodGet, Handler: ok},
+			{Name: "report", Uri: "/reports/:id", Method: http.MethodGet, Handler: ok},
+			{Uri: "/reports/:id/export", Method: http.MethodPost, Handler: ok},
+			{Name: "users", Uri: "/users", Method: http.MethodGet, Handler: ok},
+		},
+	}
+}
+
+func TestTransportServerDisableRoute(t *testing.T) {
+	server := newTestServer(t, gatedHandler())
+
+	if err := server.DisableRoute("report", 30*time.Second); err != nil {
+		t.Fatalf("expected route to be disabled, got %v", err)
+	}
+
+	w := serveRequest(server, http.MethodGet, "/api/v1/reports/1", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+
+	var envelope response.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if envelope.Error == nil || envelope.Error.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 error envelope, got %s", w.Body.String())
+	}
+
+	for _, path := range []string{"/api/v1/users", "/api/v1/health"} {
+		if w := serveRequest(server, http.MethodGet, path, nil); w.Code != http.StatusOK {
+			t.Errorf("expected %s to stay available, got %d", path, w.Code)
+		}
+	}
+
+	server.EnableRoute("report")
+
+	if w := serveRequest(server, http.MethodGet, "/api/v1/reports/1", nil); w.Code != http.StatusOK {
+		t.Errorf("expected route to be enabled again, got %d", w.Code)
+	}
+}
+
+func TestTransportServerDisableRoutePattern(t *testing.T) {
+	server := newTestServer(t, gatedHandler())
+
+	if err := server.DisableRoute("/api/v1/reports/*", 0); err != nil {
+		t.Fatalf("expected routes to be disabled, got %v", err)
+	}
+
+	w := serveRequest(server, http.MethodGet, "/api/v1/reports/1", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("expected no Retry-After header, got %q", got)
+	}
+
+	// The pattern matches one path segment, like path.Match.
+	if w := serveRequest(server, http.MethodPost, "/api/v1/reports/1/export", nil); w.Code != http.StatusOK {
+		t.Errorf("expected export to stay available, got %d", w.Code)
+	}
+}
+
+func TestTransportServerDisableRouteInvalidSelector(t *testing.T) {
+	server := newTestServer(t, gatedHandler())
+
+	if err := server.DisableRoute("missing", time.Second); !errors.Is(err, ErrUnknownRouteName) {
+		t.Errorf("expected ErrUnknownRouteName, got %v", err)
+	}
+	if err := server.DisableRoute("/api/v1/[", time.Second); err == nil {
+		t.Error("expected malformed pattern to be rejected")
+	}
+}
+
+func TestTransportServerMaintenance(t *testing.T) {
+	server := newTestServer(t, gatedHandler(), WithMaintenanceAllowlist("health", "/api/v1/reports/*"))
+
+	server.SetMaintenance(true, 2*time.Minute)
+	if !server.Maintenance() {
+		t.Fatal("expected maintenance mode to be on")
+	}
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{method: http.MethodGet, path: "/api/v1/health", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/reports/1", expectedStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/users", expectedStatus: http.StatusServiceUnavailable},
+		{method: http.MethodPost, path: "/api/v1/reports/1/export", expectedStatus: http.StatusServiceUnavailable},
+		{method: http.MethodGet, path: "/api/v1/missing", expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := serveRequest(server, tt.method, tt.path, nil)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "120" {
+				t.Errorf("expected Retry-After 120, got %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	server.SetMaintenance(false, 0)
+
+	if w := serveRequest(server, http.MethodGet, "/api/v1/users", nil); w.Code != http.StatusOK {
+		t.Errorf("expected users to be available after maintenance, got %d", w.Code)
+	}
+}
+
+func TestTransportServerMaintenanceVersionNegotiation(t *testing.T) {
+	server := newTestServer(t, gatedHandler(), WithVersionNegotiation("acme"), WithMaintenanceAllowlist("health"))
+
+	server.SetMaintenance(true, time.Minute)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/api/health", expectedStatus: http.StatusOK},
+		{path: "/api/v1/health", expectedStatus: http.StatusOK},
+		{path: "/api/users", expectedStatus: http.StatusServiceUnavailable},
+		{path: "/api/missing", expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if w := serveRequest(server, http.MethodGet, tt.path, nil); w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
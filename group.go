@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ServerGroup runs several TransportServers in one process as a unit, e.g. a public API,
+// an internal admin API and a metrics endpoint.
+type ServerGroup struct {
+	servers []*TransportServer
+
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+func NewServerGroup(servers ...*TransportServer) *ServerGroup {
+	return &ServerGroup{
+		servers:  servers,
+		stopping: make(chan struct{}),
+	}
+}
+
+// Start binds every server before serving any of them and fails fast, releasing the sockets
+// already bound, if one cannot listen. It blocks until the group is stopped and returns
+// http.ErrServerClosed, or until a server fails: the rest are then stopped and the first
+// fatal error is returned.
+func (g *ServerGroup) Start() error {
+	for i, s := range g.servers {
+		if err := s.Listen(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = g.servers[j].Stop(context.Background())
+			}
+			return err
+		}
+	}
+
+	results := make(chan error, len(g.servers))
+	for _, s := range g.servers {
+		go func(s *TransportServer) {
+			results <- s.Serve()
+		}(s)
+	}
+
+	var firstErr error
+	for range g.servers {
+		err := <-results
+		if firstErr != nil || errors.Is(err, http.ErrServerClosed) || g.isStopping() {
+			continue
+		}
+
+		firstErr = err
+		ctx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout())
+		_ = g.Stop(ctx)
+		cancel()
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return http.ErrServerClosed
+}
+
+// Stop shuts the servers down in reverse order, all within the deadline of ctx.
+func (g *ServerGroup) Stop(ctx context.Context) error {
+	g.stopOnce.Do(func() {
+		close(g.stopping)
+	})
+
+	errs := make([]error, 0, len(g.servers))
+	for i := len(g.servers) - 1; i >= 0; i-- {
+		errs = append(errs, g.servers[i].Stop(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (g *ServerGroup) isStopping() bool {
+	select {
+	case <-g.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *ServerGroup) shutdownTimeout() (timeout time.Duration) {
+	for _, s := range g.servers {
+		timeout = max(timeout, s.cfg.shutdownTimeout)
+	}
+
+	return timeout
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closeRecorder records the order in which listeners are closed.
+type closeRecorder struct {
+	mu     sync.Mutex
+	closed []string
+}
+
+func (r *closeRecorder) listen(t *testing.T, name string) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	return &recordingListener{Listener: l, name: name, recorder: r}
+}
+
+func (r *closeRecorder) order() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.closed...)
+}
+
+type recordingListener struct {
+	net.Listener
+	name     string
+	recorder *closeRecorder
+	once     sync.Once
+}
+
+func (l *recordingListener) Close() error {
+	l.once.Do(func() {
+		l.recorder.mu.Lock()
+		l.recorder.closed = append(l.recorder.closed, l.name)
+		l.recorder.mu.Unlock()
+	})
+
+	return l.Listener.Close()
+}
+
+func newGroupMember(t *testing.T, l net.Listener, opts ...Option) *TransportServer {
+	t.Helper()
+
+	server := NewTransportServer(append([]Option{WithMode(MODE_TEST), WithListener(l)}, opts...)...)
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	return server
+}
+
+func startGroup(t *testing.T, group *ServerGroup, servers ...*TransportServer) chan error {
+	t.Helper()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- group.Start()
+	}()
+
+	for _, server := range servers {
+		select {
+		case <-server.Ready():
+		case err := <-errChan:
+			t.Fatalf("group failed to start: %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("group did not start in time")
+		}
+	}
+
+	return errChan
+}
+
+func TestServerGroupStartStop(t *testing.T) {
+	recorder := &closeRecorder{}
+	public := newGroupMember(t, recorder.listen(t, "public"))
+	admin := newGroupMember(t, recorder.listen(t, "admin"))
+	metrics := newGroupMember(t, recorder.listen(t, "metrics"))
+
+	group := NewServerGroup(public, admin, metrics)
+	errChan := startGroup(t, group, public, admin, metrics)
+
+	for _, server := range []*TransportServer{public, admin, metrics} {
+		expectPong(t, http.DefaultClient, "http://"+server.Addr().String()+"/api/v1/ping")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := group.Stop(ctx); err != nil {
+		t.Errorf("expected no error on stop, got %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("group did not stop in time")
+	}
+
+	expected := []string{"metrics", "admin", "public"}
+	order := recorder.order()
+	if len(order) != len(expected) {
+		t.Fatalf("expected servers to stop in order %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("expected servers to stop in order %v, got %v", expected, order)
+			break
+		}
+	}
+}
+
+func TestServerGroupFailsFastOnBindError(t *testing.T) {
+	recorder := &closeRecorder{}
+	public := newGroupMember(t, recorder.listen(t, "public"))
+	broken := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithUnixSocket(filepath.Join(os.TempDir(), "nonexistent-dir", "api.sock"), 0),
+	)
+
+	if err := NewServerGroup(public, broken).Start(); err == nil {
+		t.Fatal("expected bind error")
+	}
+
+	if order := recorder.order(); len(order) != 1 || order[0] != "public" {
+		t.Errorf("expected already bound listener to be released, got %v", order)
+	}
+}
+
+func TestServerGroupPropagatesFatalError(t *testing.T) {
+	recorder := &closeRecorder{}
+	public := newGroupMember(t, recorder.listen(t, "public"))
+	broken := newGroupMember(t, recorder.listen(t, "broken"), WithTLS("missing.crt", "missing.key"))
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- NewServerGroup(public, broken).Start()
+	}()
+
+	select {
+	case err := <-errChan:
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected the fatal error of the broken server, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("group did not stop after a server failed")
+	}
+
+	if order := recorder.order(); len(order) != 2 {
+		t.Errorf("expected both servers to be stopped, got %v", order)
+	}
+}
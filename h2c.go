@@ -0,0 +1,20 @@
+package http
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var ErrH2CWithTLS = errors.New("h2c serves cleartext HTTP/2 and cannot be combined with TLS")
+
+func h2cHandler(next http.Handler, tlsConfig *tls.Config) (http.Handler, error) {
+	if tlsConfig != nil {
+		return nil, ErrH2CWithTLS
+	}
+
+	return h2c.NewHandler(next, &http2.Server{}), nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http2"
+)
+
+func TestTransportServerH2CWithTLS(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := newTestServerCert(t, ca).writeFiles(t, t.TempDir())
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithTLS(certFile, keyFile),
+		WithH2C(),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Start(); !errors.Is(err, ErrH2CWithTLS) {
+		t.Errorf("expected ErrH2CWithTLS, got %v", err)
+	}
+}
+
+func TestTransportServerH2C(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithH2C(),
+	)
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/proto",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, c.Request.Proto)
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	t.Run("prior knowledge", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			},
+		}
+
+		resp, err := client.Get("http://" + addr + "/api/v1/proto")
+		if err != nil {
+			t.Fatalf("expected h2c request to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "HTTP/2.0" {
+			t.Errorf("expected request to be served over HTTP/2, got %q", body)
+		}
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "GET /api/v1/proto HTTP/1.1\r\n"+
+			"Host: localhost\r\n"+
+			"Connection: Upgrade, HTTP2-Settings\r\n"+
+			"Upgrade: h2c\r\n"+
+			"HTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n")
+		if err != nil {
+			t.Fatalf("failed to write upgrade request: %v", err)
+		}
+
+		status, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if !strings.HasPrefix(status, "HTTP/1.1 101") {
+			t.Errorf("expected 101 Switching Protocols, got %q", status)
+		}
+	})
+
+	t.Run("plain HTTP/1.1 still works", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/api/v1/proto")
+		if err != nil {
+			t.Fatalf("expected request to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "HTTP/1.1" {
+			t.Errorf("expected request to be served over HTTP/1.1, got %q", body)
+		}
+	})
+}
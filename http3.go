@@ -0,0 +1,46 @@
+package http
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+var (
+	ErrHTTP3RequiresTLS = errors.New("HTTP/3 requires TLS to be configured")
+	ErrHTTP3RequiresTCP = errors.New("HTTP/3 requires a TCP listener to share its port with")
+)
+
+func newHTTP3Server(addr net.Addr, handler http.Handler, tlsConfig *tls.Config) (*http3.Server, net.PacketConn, error) {
+	if tlsConfig == nil {
+		return nil, nil, ErrHTTP3RequiresTLS
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil, nil, ErrHTTP3RequiresTCP
+	}
+
+	conn, err := net.ListenPacket("udp", tcpAddr.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h3 := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+	}
+
+	return h3, conn, nil
+}
+
+// altSvcHandler advertises the HTTP/3 listener on responses served over TCP.
+func altSvcHandler(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = h3.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
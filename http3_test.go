@@ -0,0 +1,101 @@
+package http
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestTransportServerHTTP3RequiresTLS(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithHTTP3(),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Start(); !errors.Is(err, ErrHTTP3RequiresTLS) {
+		t.Errorf("expected ErrHTTP3RequiresTLS, got %v", err)
+	}
+}
+
+func TestTransportServerHTTP3(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := newTestServerCert(t, ca)
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithTLSConfig(&tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{serverCert.tlsCertificate(t)},
+		}),
+		WithHTTP3(),
+	)
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/proto",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, c.Request.Proto)
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+
+	clientTLS := &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS12}
+
+	t.Run("TCP response advertises HTTP/3", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+
+		resp, err := client.Get("https://" + addr + "/api/v1/proto")
+		if err != nil {
+			t.Fatalf("expected request to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		expected := fmt.Sprintf(`h3=":%d"; ma=2592000`, server.Addr().(*net.TCPAddr).Port)
+		if got := resp.Header.Get("Alt-Svc"); got != expected {
+			t.Errorf("unexpected Alt-Svc header %q", got)
+		}
+	})
+
+	t.Run("QUIC request", func(t *testing.T) {
+		transport := &http3.Transport{TLSClientConfig: clientTLS}
+		defer transport.Close()
+
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + addr + "/api/v1/proto")
+		if err != nil {
+			t.Fatalf("expected HTTP/3 request to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "HTTP/3.0" {
+			t.Errorf("expected request to be served over HTTP/3, got %q", body)
+		}
+	})
+
+	stopServer(t, server, errChan)
+
+	server.mu.RLock()
+	h3 := server.http3Server
+	server.mu.RUnlock()
+	if err := h3.Serve(server.packetConn); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected HTTP/3 server to be closed after Stop, got %v", err)
+	}
+}
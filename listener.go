@@ -0,0 +1,76 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+func (s *TransportServer) listen() (net.Listener, error) {
+	if l, ok, err := inheritedListener(); ok {
+		s.inherited = err == nil
+		return l, err
+	}
+
+	switch {
+	case s.cfg.listener != nil:
+		return s.cfg.listener, nil
+	case s.cfg.systemdListener:
+		listeners, err := SystemdListeners()
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range listeners[1:] {
+			_ = l.Close()
+		}
+		return listeners[0], nil
+	case s.cfg.unixSocket != "":
+		return listenUnix(s.cfg.unixSocket, s.cfg.unixSocketMode)
+	default:
+		return net.Listen("tcp", fmt.Sprintf("%s:%d", s.cfg.host, s.cfg.port))
+	}
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("chmod unix socket %s: %w", path, err)
+		}
+	}
+
+	return l, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a previous process,
+// refusing to touch it while something is still accepting connections on it.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unix socket path %s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+
+	return os.Remove(path)
+}
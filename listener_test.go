@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func pingHandler() *mockHandler {
+	return &mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/ping",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, "pong")
+				},
+			},
+		},
+	}
+}
+
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+
+	// Unix socket paths are limited to ~108 bytes, t.TempDir() can exceed that.
+	dir, err := os.MkdirTemp("", "gu")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+func expectPong(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "pong" {
+		t.Errorf("expected body %q, got %q", "pong", body)
+	}
+}
+
+func TestTransportServerUnixSocket(t *testing.T) {
+	socket := filepath.Join(shortTempDir(t), "api.sock")
+
+	// Leave a stale socket behind, as a crashed process would.
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithUnixSocket(socket, 0o660),
+	)
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start()
+	}()
+
+	client := unixClient(socket)
+	waitForUnix(t, socket)
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("expected socket file to exist, got %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o660 {
+		t.Errorf("expected socket mode %o, got %o", 0o660, perm)
+	}
+
+	expectPong(t, client, "http://unix/api/v1/ping")
+
+	stopServer(t, server, errChan)
+
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("expected socket file to be removed after stop, got %v", err)
+	}
+}
+
+func waitForUnix(t *testing.T, path string) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("server at %s did not start in time", path)
+}
+
+func TestListenUnixRefusesActiveSocket(t *testing.T) {
+	socket := filepath.Join(shortTempDir(t), "busy.sock")
+
+	active, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	defer active.Close()
+
+	if _, err := listenUnix(socket, 0); err == nil {
+		t.Error("expected error when socket is in use")
+	}
+}
+
+func TestListenUnixRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "file.sock")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	if _, err := listenUnix(path, 0); err == nil {
+		t.Error("expected error when path is not a socket")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected regular file to be kept, got %v", err)
+	}
+}
+
+func TestTransportServerWithListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithListener(l),
+	)
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start()
+	}()
+	defer stopServer(t, server, errChan)
+
+	expectPong(t, http.DefaultClient, "http://"+l.Addr().String()+"/api/v1/ping")
+}
+
+func TestTransportServerStartWithListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.StartWithListener(l)
+	}()
+	defer stopServer(t, server, errChan)
+
+	expectPong(t, http.DefaultClient, "http://"+l.Addr().String()+"/api/v1/ping")
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("auth: invalid api key")
+	ErrNoKeyStore    = errors.New("auth: no key store")
+)
+
+// StoredKey is an API key as kept by a KeyStore.
+type StoredKey struct {
+	// Hash is the SHA-256 of the key, see HashAPIKey, so stores need not keep keys in clear.
+	Hash    []byte
+	Subject string
+	Claims  any
+}
+
+// KeyStore looks API keys up by their id. It returns a nil key for unknown ids.
+type KeyStore interface {
+	LookupKey(ctx context.Context, id string) (*StoredKey, error)
+}
+
+// StaticKeyStore is a fixed KeyStore, keyed by key id.
+type StaticKeyStore map[string]StoredKey
+
+func (s StaticKeyStore) LookupKey(_ context.Context, id string) (*StoredKey, error) {
+	key, ok := s[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &key, nil
+}
+
+// HashAPIKey returns the hash a StoredKey keeps for key.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+
+	return sum[:]
+}
+
+// APIKeyConfig configures NewAPIKey.
+type APIKeyConfig struct {
+	Store KeyStore
+	// Header carries the key, X-API-Key by default.
+	Header string
+	// Query names a query parameter that may carry the key instead, off when empty.
+	Query string
+	// KeyID extracts the id a key is looked up by, by default the part before the first
+	// dot of keys like "k1.s3cr3t".
+	KeyID func(key string) string
+	// Realm is sent in the WWW-Authenticate challenge.
+	Realm string
+}
+
+// APIKey authenticates requests by API key.
+type APIKey struct {
+	config APIKeyConfig
+}
+
+func NewAPIKey(config APIKeyConfig) (*APIKey, error) {
+	if config.Store == nil {
+		return nil, ErrNoKeyStore
+	}
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	if config.KeyID == nil {
+		config.KeyID = func(key string) string {
+			id, _, _ := strings.Cut(key, ".")
+			return id
+		}
+	}
+
+	return &APIKey{config: config}, nil
+}
+
+// APIKeyMiddleware is Middleware for NewAPIKey.
+func APIKeyMiddleware(config APIKeyConfig) (gin.HandlerFunc, error) {
+	a, err := NewAPIKey(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return Middleware(a), nil
+}
+
+func (a *APIKey) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(a.config.Header)
+	if key == "" && a.config.Query != "" {
+		key = r.URL.Query().Get(a.config.Query)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	stored, err := a.config.Store.LookupKey(r.Context(), a.config.KeyID(key))
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	// Unknown ids are compared too, so both failures take the same time.
+	expected := make([]byte, sha256.Size)
+	if stored != nil {
+		expected = stored.Hash
+	}
+	if subtle.ConstantTimeCompare(HashAPIKey(key), expected) != 1 || stored == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &Principal{Scheme: "ApiKey", Subject: stored.Subject, Claims: stored.Claims}, nil
+}
+
+func (a *APIKey) Challenge(error) string {
+	return challenge("ApiKey", "realm", a.config.Realm)
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type failingKeyStore struct{}
+
+func (failingKeyStore) LookupKey(context.Context, string) (*StoredKey, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestAPIKey(t *testing.T) {
+	a, err := NewAPIKey(APIKeyConfig{
+		Store: StaticKeyStore{
+			"k1": {Hash: HashAPIKey("k1.s3cr3t"), Subject: "billing-service"},
+		},
+		Query: "api_key",
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		url         string
+		expectedErr error
+	}{
+		{name: "header", header: "k1.s3cr3t", url: "/test"},
+		{name: "query", url: "/test?api_key=k1.s3cr3t"},
+		{name: "missing", url: "/test", expectedErr: ErrNoCredentials},
+		{name: "wrong secret", header: "k1.guess", url: "/test", expectedErr: ErrInvalidAPIKey},
+		{name: "unknown id", header: "k2.s3cr3t", url: "/test", expectedErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+
+			principal, err := a.Authenticate(req)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && (principal.Subject != "billing-service" || principal.Scheme != "ApiKey") {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestAPIKeyStoreError(t *testing.T) {
+	a, _ := NewAPIKey(APIKeyConfig{Store: failingKeyStore{}})
+
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-API-Key", "k1.s3cr3t")
+
+	if _, err := a.Authenticate(req); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/test", Middleware(a), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("WWW-Authenticate") != "" {
+		t.Errorf("expected 503 without a challenge, got %d %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	if _, err := NewAPIKey(APIKeyConfig{}); !errors.Is(err, ErrNoKeyStore) {
+		t.Errorf("expected ErrNoKeyStore, got %v", err)
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	middleware, err := APIKeyMiddleware(APIKeyConfig{
+		Store: StaticKeyStore{"k1": {Hash: HashAPIKey("k1.s3cr3t"), Subject: "billing-service"}},
+		Realm: "api",
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/test", middleware, func(c *gin.Context) {
+		c.String(http.StatusOK, GetPrincipal(c).Subject)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-API-Key", "k1.s3cr3t")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "billing-service" {
+		t.Errorf("expected the key to authenticate, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/test", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != `ApiKey realm="api"` {
+		t.Errorf("unexpected challenge %q", got)
+	}
+}
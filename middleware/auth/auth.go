@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries none of
+	// its credentials, as opposed to credentials it rejects.
+	ErrNoCredentials = errors.New("auth: no credentials")
+	// ErrUnavailable wraps failures of what an Authenticator depends on, e.g. a JWKS
+	// endpoint or a key store. They say nothing about the credentials, so they are
+	// answered with 503 and never sent to the client.
+	ErrUnavailable = errors.New("auth: authentication unavailable")
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// Scheme names the authentication scheme, e.g. "Bearer".
+	Scheme  string
+	Subject string
+	// Claims holds the scheme specific data, e.g. the typed claims of a JWT.
+	Claims any
+}
+
+// Authenticator authenticates requests with one scheme.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+	// Challenge returns the WWW-Authenticate value for a request rejected with err.
+	Challenge(err error) string
+}
+
+type principalKey struct{}
+
+var principalKeyCtx = principalKey{}
+
+// Middleware rejects requests a does not authenticate with 401 Unauthorized and stores
+// the principal of the others, e.g. for WithAuthMiddleware. ErrUnavailable errors abort
+// with 503 Service Unavailable instead.
+func Middleware(a Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := a.Authenticate(c.Request)
+		if errors.Is(err, ErrUnavailable) {
+			Unavailable(c, err)
+			return
+		}
+		if err != nil {
+			Unauthorized(c, a.Challenge(err))
+			return
+		}
+
+		SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// Unauthorized aborts with 401 Unauthorized, sending a WWW-Authenticate header per challenge.
+func Unauthorized(c *gin.Context, challenges ...string) {
+	for _, challenge := range challenges {
+		c.Writer.Header().Add("WWW-Authenticate", challenge)
+	}
+
+	response.Abort(c, http.StatusUnauthorized, "Unauthorized")
+}
+
+// Unavailable aborts with 503 Service Unavailable. err is attached to the context for the
+// logs only.
+func Unavailable(c *gin.Context, err error) {
+	_ = c.Error(err)
+	response.Abort(c, http.StatusServiceUnavailable, "Service Unavailable")
+}
+
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKeyCtx, principal)
+}
+
+// GetPrincipal returns the authenticated caller, or nil for anonymous requests.
+func GetPrincipal(c *gin.Context) *Principal {
+	value, _ := c.Get(principalKeyCtx)
+	principal, _ := value.(*Principal)
+
+	return principal
+}
+
+// GetClaims returns the claims of the authenticated caller, e.g. GetClaims[MyClaims](c)
+// behind a JWT authenticator created with NewJWT[MyClaims].
+func GetClaims[T any](c *gin.Context) (T, bool) {
+	var zero T
+
+	principal := GetPrincipal(c)
+	if principal == nil {
+		return zero, false
+	}
+
+	claims, ok := principal.Claims.(T)
+
+	return claims, ok
+}
+
+// challenge formats a WWW-Authenticate value like `Bearer realm="api", error="invalid_token"`.
+func challenge(scheme string, params ...string) string {
+	var b strings.Builder
+	b.WriteString(scheme)
+
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] == "" {
+			continue
+		}
+		if b.Len() > len(scheme) {
+			b.WriteString(",")
+		}
+		b.WriteString(" " + params[i] + `="` + strings.ReplaceAll(params[i+1], `"`, `'`) + `"`)
+	}
+
+	return b.String()
+}
+
+// unavailable wraps err in ErrUnavailable.
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+// describe returns the text of the first of sentinels that err wraps, without the details
+// around it, or "" when err wraps none of them.
+func describe(err error, sentinels []error) string {
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return strings.TrimPrefix(sentinel.Error(), "auth: ")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hs := newSigningKeys(t)[0]
+
+	middleware, err := JWTMiddleware[testClaims](JWTConfig{
+		Keys:  StaticKeys{{ID: hs.kid, Key: hs.public}},
+		Realm: "api",
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/test", middleware, func(c *gin.Context) {
+		claims, ok := GetClaims[testClaims](c)
+		if !ok {
+			t.Error("expected typed claims in the context")
+		}
+		c.String(http.StatusOK, GetPrincipal(c).Subject+" "+claims.Role)
+	})
+
+	tests := []struct {
+		name              string
+		token             string
+		expectedStatus    int
+		expectedChallenge string
+	}{
+		{
+			name:           "valid token",
+			token:          signToken(t, hs, validClaims()),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:              "missing token",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer realm="api"`,
+		},
+		{
+			name:              "invalid token",
+			token:             tamper(signToken(t, hs, validClaims())),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer realm="api", error="invalid_token", error_description="invalid signature"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, bearerRequest(tt.token))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.expectedChallenge {
+				t.Errorf("expected WWW-Authenticate %q, got %q", tt.expectedChallenge, got)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				if got := w.Body.String(); got != "user-1 admin" {
+					t.Errorf("unexpected body %q", got)
+				}
+				return
+			}
+
+			var envelope response.Envelope
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if envelope.Error == nil || envelope.Error.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401 error envelope, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetClaimsAnonymous(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	if _, ok := GetClaims[testClaims](c); ok {
+		t.Error("expected no claims without a principal")
+	}
+
+	SetPrincipal(c, &Principal{Claims: "other"})
+	if _, ok := GetClaims[testClaims](c); ok {
+		t.Error("expected claims of another type to be rejected")
+	}
+}
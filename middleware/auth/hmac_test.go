@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var hmacSecret = []byte("shared-secret")
+
+func newHMAC(t *testing.T) *HMAC {
+	t.Helper()
+
+	h, err := NewHMAC(HMACConfig{
+		Keys:        StaticHMACKeyStore{"k1": {Secret: hmacSecret, Subject: "billing-service"}},
+		Nonces:      NewMemoryNonceStore(),
+		MaxBodySize: 64,
+		Realm:       "api",
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	return h
+}
+
+func signedRequest(t *testing.T, method, url, body, keyID string) *http.Request {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if err := SignRequest(req, keyID, hmacSecret); err != nil {
+		t.Fatalf("failed to sign request: %v", err)
+	}
+
+	return req
+}
+
+func TestHMAC(t *testing.T) {
+	h := newHMAC(t)
+
+	req := signedRequest(t, http.MethodPost, "/orders?dry_run=1", `{"id":1}`, "k1")
+
+	principal, err := h.Authenticate(req)
+	if err != nil {
+		t.Fatalf("expected request to authenticate, got %v", err)
+	}
+	if principal.Subject != "billing-service" || principal.Scheme != "HMAC" {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"id":1}` {
+		t.Errorf("expected the body to be restored, got %q", body)
+	}
+}
+
+func TestHMACRewrittenPath(t *testing.T) {
+	req := signedRequest(t, http.MethodGet, "/api/orders?page=2", "", "k1")
+
+	// As received by a server whose handlers rewrote the path, e.g. version negotiation.
+	req.RequestURI = "/api/orders?page=2"
+	req.URL.Path = "/api/v1/orders"
+
+	if _, err := newHMAC(t).Authenticate(req); err != nil {
+		t.Errorf("expected the received request uri to be verified, got %v", err)
+	}
+}
+
+func TestHMACRejections(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     func(t *testing.T) *http.Request
+		expectedErr error
+	}{
+		{
+			name: "no credentials",
+			request: func(t *testing.T) *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+				return req
+			},
+			expectedErr: ErrNoCredentials,
+		},
+		{
+			name: "malformed",
+			request: func(t *testing.T) *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+				req.Header.Set("Authorization", `HMAC keyId="k1"`)
+				return req
+			},
+			expectedErr: ErrMalformedCredentials,
+		},
+		{
+			name: "unknown key",
+			request: func(t *testing.T) *http.Request {
+				return signedRequest(t, http.MethodGet, "/orders", "", "k2")
+			},
+			expectedErr: ErrUnknownKey,
+		},
+		{
+			name: "tampered path",
+			request: func(t *testing.T) *http.Request {
+				req := signedRequest(t, http.MethodGet, "/orders", "", "k1")
+				req.URL.Path = "/admin"
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered request uri",
+			request: func(t *testing.T) *http.Request {
+				req := signedRequest(t, http.MethodGet, "/orders", "", "k1")
+				req.RequestURI = "/admin"
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered body",
+			request: func(t *testing.T) *http.Request {
+				req := signedRequest(t, http.MethodPost, "/orders", `{"amount":1}`, "k1")
+				req.Body = io.NopCloser(strings.NewReader(`{"amount":1000}`))
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "stale timestamp",
+			request: func(t *testing.T) *http.Request {
+				req := signedRequest(t, http.MethodGet, "/orders", "", "k1")
+				old := time.Now().Add(-time.Hour).Unix()
+				req.Header.Set("Authorization", replaceParam(req.Header.Get("Authorization"), "ts", old))
+				return req
+			},
+			expectedErr: ErrStaleRequest,
+		},
+		{
+			name: "body too large",
+			request: func(t *testing.T) *http.Request {
+				return signedRequest(t, http.MethodPost, "/orders", strings.Repeat("x", 65), "k1")
+			},
+			expectedErr: ErrBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newHMAC(t).Authenticate(tt.request(t)); !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestHMACReplay(t *testing.T) {
+	h := newHMAC(t)
+	req := signedRequest(t, http.MethodGet, "/orders", "", "k1")
+	authorization := req.Header.Get("Authorization")
+
+	if _, err := h.Authenticate(req); err != nil {
+		t.Fatalf("expected request to authenticate, got %v", err)
+	}
+
+	replay, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+	replay.Header.Set("Authorization", authorization)
+	if _, err := h.Authenticate(replay); !errors.Is(err, ErrReplayedRequest) {
+		t.Errorf("expected ErrReplayedRequest, got %v", err)
+	}
+}
+
+type failingHMACStore struct{}
+
+func (failingHMACStore) LookupHMACKey(context.Context, string) (*HMACKey, error) {
+	return nil, errors.New("pq: connection to db-primary.internal:5432 refused")
+}
+
+func (failingHMACStore) UseNonce(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("redis: dial tcp 10.0.0.7:6379: connection refused")
+}
+
+func TestHMACStoreFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HMACConfig
+	}{
+		{
+			name:   "key store",
+			config: HMACConfig{Keys: failingHMACStore{}, Nonces: NewMemoryNonceStore(), Realm: "api"},
+		},
+		{
+			name: "nonce store",
+			config: HMACConfig{
+				Keys:   StaticHMACKeyStore{"k1": {Secret: hmacSecret}},
+				Nonces: failingHMACStore{},
+				Realm:  "api",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := NewHMAC(tt.config)
+
+			_, err := h.Authenticate(signedRequest(t, http.MethodGet, "/orders", "", "k1"))
+			if !errors.Is(err, ErrUnavailable) {
+				t.Fatalf("expected ErrUnavailable, got %v", err)
+			}
+			if got := h.Challenge(err); got != `HMAC realm="api"` {
+				t.Errorf("expected the store failure not to be sent, got %q", got)
+			}
+		})
+	}
+}
+
+func TestHMACChallenge(t *testing.T) {
+	h := newHMAC(t)
+
+	if got := h.Challenge(fmt.Errorf("%w: %q", ErrUnknownKey, "k9")); got != `HMAC realm="api", error="unknown signing key"` {
+		t.Errorf("expected the details to be left out, got %q", got)
+	}
+	if got := h.Challenge(errors.New("read tcp 10.0.0.1:443: reset")); got != `HMAC realm="api"` {
+		t.Errorf("expected other errors not to be described, got %q", got)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	ctx := context.Background()
+
+	if fresh, _ := store.UseNonce(ctx, "a", time.Now().Add(time.Minute)); !fresh {
+		t.Error("expected a new nonce to be fresh")
+	}
+	if fresh, _ := store.UseNonce(ctx, "a", time.Now().Add(time.Minute)); fresh {
+		t.Error("expected a used nonce to be rejected")
+	}
+
+	if fresh, _ := store.UseNonce(ctx, "b", time.Now().Add(-time.Second)); !fresh {
+		t.Error("expected a new nonce to be fresh")
+	}
+	if fresh, _ := store.UseNonce(ctx, "b", time.Now().Add(time.Minute)); !fresh {
+		t.Error("expected an expired nonce to be usable again")
+	}
+}
+
+func TestHMACMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	middleware, err := HMACMiddleware(HMACConfig{
+		Keys:   StaticHMACKeyStore{"k1": {Secret: hmacSecret, Subject: "billing-service"}},
+		Nonces: NewMemoryNonceStore(),
+		Realm:  "api",
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+	if _, err := HMACMiddleware(HMACConfig{Keys: StaticHMACKeyStore{}}); !errors.Is(err, ErrNoNonceStore) {
+		t.Errorf("expected ErrNoNonceStore, got %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/orders", middleware, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, GetPrincipal(c).Subject+" "+string(body))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest(t, http.MethodPost, "/orders", "payload", "k1"))
+	if w.Code != http.StatusOK || w.Body.String() != "billing-service payload" {
+		t.Errorf("expected the request to authenticate, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req := signedRequest(t, http.MethodPost, "/orders", "payload", "k1")
+	req.Body = io.NopCloser(strings.NewReader("changed"))
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != `HMAC realm="api", error="invalid signature"` {
+		t.Errorf("unexpected challenge %q", got)
+	}
+}
+
+func replaceParam(authorization, name string, value int64) string {
+	params := strings.Split(strings.TrimPrefix(authorization, "HMAC "), ", ")
+	for i, param := range params {
+		if strings.HasPrefix(param, name+"=") {
+			params[i] = name + `="` + strconv.FormatInt(value, 10) + `"`
+		}
+	}
+
+	return "HMAC " + strings.Join(params, ", ")
+}
@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var errInvalidJWK = errors.New("invalid json web key")
+
+const (
+	// jwksMinRefresh limits reloads triggered by tokens naming unknown keys.
+	jwksMinRefresh = 10 * time.Second
+	// jwksFetchTimeout bounds fetches made with the default client of NewJWKSURL.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWKS is a KeySet read from a JSON Web Key Set. Keys are cached for ttl and reloaded
+// early when a token names an unknown key, so rotated keys are picked up. Only one reload
+// runs at a time and the cached keys stay in use while it runs or when it fails.
+type JWKS struct {
+	load func(ctx context.Context) ([]byte, error)
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	keys      []Key
+	loadedAt  time.Time
+	reloading *jwksReload
+}
+
+// jwksReload is a reload in flight; err is set before done is closed.
+type jwksReload struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSFile reads the key set from a file.
+func NewJWKSFile(path string, ttl time.Duration) *JWKS {
+	return &JWKS{
+		ttl: ttl,
+		load: func(context.Context) ([]byte, error) {
+			return os.ReadFile(path)
+		},
+	}
+}
+
+// NewJWKSURL fetches the key set from url with client, a client with a 10 second
+// timeout if nil.
+func NewJWKSURL(url string, client *http.Client, ttl time.Duration) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+
+	return &JWKS{
+		ttl: ttl,
+		load: func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("jwks %s: unexpected status %d", url, resp.StatusCode)
+			}
+
+			return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		},
+	}
+}
+
+func (k *JWKS) Keys(ctx context.Context, kid string) ([]Key, error) {
+	k.mu.Lock()
+	keys := k.keys
+	age := time.Since(k.loadedAt)
+	unknown := kid != "" && !slices.ContainsFunc(keys, func(key Key) bool { return key.ID == kid })
+
+	if keys != nil && age < k.ttl && (!unknown || age < jwksMinRefresh) {
+		k.mu.Unlock()
+		return keys, nil
+	}
+	reload := k.startReload(ctx)
+	k.mu.Unlock()
+
+	// Only a first load or an unknown key waits for the reload.
+	if keys != nil && !unknown {
+		return keys, nil
+	}
+
+	select {
+	case <-reload.done:
+	case <-ctx.Done():
+		if keys != nil {
+			return keys, nil
+		}
+		return nil, ctx.Err()
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys == nil {
+		return nil, reload.err
+	}
+
+	return k.keys, nil
+}
+
+// Refresh reloads the key set, e.g. to fail fast on startup.
+func (k *JWKS) Refresh(ctx context.Context) error {
+	k.mu.Lock()
+	reload := k.startReload(ctx)
+	k.mu.Unlock()
+
+	select {
+	case <-reload.done:
+		return reload.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startReload starts a reload unless one is running already and returns it. The caller
+// holds k.mu. The reload outlives the request that started it, so it is not cancelled
+// with ctx.
+func (k *JWKS) startReload(ctx context.Context) *jwksReload {
+	if k.reloading != nil {
+		return k.reloading
+	}
+
+	reload := &jwksReload{done: make(chan struct{})}
+	k.reloading = reload
+	k.loadedAt = time.Now()
+
+	go func() {
+		keys, err := k.fetch(context.WithoutCancel(ctx))
+
+		k.mu.Lock()
+		if err == nil {
+			k.keys = keys
+		}
+		k.reloading = nil
+		k.mu.Unlock()
+
+		reload.err = err
+		close(reload.done)
+	}()
+
+	return reload
+}
+
+func (k *JWKS) fetch(ctx context.Context) ([]Key, error) {
+	data, err := k.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load jwks: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS decodes the signature keys of a JSON Web Key Set. Keys of other types or
+// uses are skipped.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+
+	var keys []Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, alg, err := jwk.decode()
+		if err != nil || key == nil {
+			continue
+		}
+		if jwk.Alg != "" && jwk.Alg != alg {
+			continue
+		}
+
+		keys = append(keys, Key{ID: jwk.Kid, Algorithm: alg, Key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return keys, nil
+}
+
+func (jwk jsonWebKey) decode() (any, string, error) {
+	switch {
+	case jwk.Kty == "oct":
+		k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err == nil && len(k) == 0 {
+			err = errInvalidJWK
+		}
+		return k, HS256, err
+	case jwk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, "", errInvalidJWK
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, RS256, nil
+	case jwk.Kty == "EC" && jwk.Crv == "P-256":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, "", errInvalidJWK
+		}
+		k, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		return k, ES256, err
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, "", errInvalidJWK
+		}
+		return ed25519.PublicKey(x), EdDSA, nil
+	}
+
+	return nil, "", nil
+}
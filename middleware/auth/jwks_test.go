@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func jwkOf(t *testing.T, key signingKey) map[string]string {
+	t.Helper()
+
+	switch k := key.public.(type) {
+	case []byte:
+		return map[string]string{"kty": "oct", "kid": key.kid, "k": b64(k)}
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": key.kid, "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		point, err := k.Bytes()
+		if err != nil {
+			t.Fatalf("failed to encode ecdsa key: %v", err)
+		}
+		return map[string]string{"kty": "EC", "kid": key.kid, "crv": "P-256", "x": b64(point[1:33]), "y": b64(point[33:])}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": key.kid, "crv": "Ed25519", "x": b64(k)}
+	}
+
+	t.Fatalf("unsupported key %T", key.public)
+	return nil
+}
+
+func jwksDocument(t *testing.T, keys ...signingKey) []byte {
+	t.Helper()
+
+	set := map[string][]map[string]string{"keys": {}}
+	for _, key := range keys {
+		set["keys"] = append(set["keys"], jwkOf(t, key))
+	}
+	data, _ := json.Marshal(set)
+
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys := newSigningKeys(t)
+
+	document := jwksDocument(t, keys...)
+	var set map[string][]map[string]string
+	_ = json.Unmarshal(document, &set)
+	set["keys"] = append(set["keys"],
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		map[string]string{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+	)
+	document, _ = json.Marshal(set)
+
+	parsed, err := ParseJWKS(document)
+	if err != nil {
+		t.Fatalf("expected key set to parse, got %v", err)
+	}
+	if len(parsed) != len(keys) {
+		t.Fatalf("expected %d signature keys, got %d", len(keys), len(parsed))
+	}
+
+	j, _ := NewJWT[testClaims](JWTConfig{Keys: StaticKeys(parsed)})
+	for _, key := range keys {
+		if _, err := j.Authenticate(bearerRequest(signToken(t, key, validClaims()))); err != nil {
+			t.Errorf("expected %s token to verify with the parsed key, got %v", key.alg, err)
+		}
+	}
+
+	if _, err := ParseJWKS([]byte(`{"keys":[]}`)); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	keys := newSigningKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	if err := os.WriteFile(path, jwksDocument(t, keys[2]), 0o600); err != nil {
+		t.Fatalf("failed to write key set: %v", err)
+	}
+
+	jwks := NewJWKSFile(path, time.Hour)
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatalf("expected key set to load, got %v", err)
+	}
+
+	j, _ := NewJWT[testClaims](JWTConfig{Keys: jwks})
+	if _, err := j.Authenticate(bearerRequest(signToken(t, keys[2], validClaims()))); err != nil {
+		t.Errorf("expected token to verify, got %v", err)
+	}
+
+	// A removed file keeps the cached keys in use.
+	_ = os.Remove(path)
+	jwks.loadedAt = time.Time{}
+	if _, err := j.Authenticate(bearerRequest(signToken(t, keys[2], validClaims()))); err != nil {
+		t.Errorf("expected cached keys to stay in use, got %v", err)
+	}
+
+	if err := NewJWKSFile(path, time.Hour).Refresh(context.Background()); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
+
+func TestJWKSURLRotation(t *testing.T) {
+	keys := newSigningKeys(t)
+	oldKey, newKey := keys[1], keys[3]
+
+	var document atomic.Value
+	document.Store(jwksDocument(t, oldKey))
+	var fetches atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(document.Load().([]byte))
+	}))
+	defer server.Close()
+
+	jwks := NewJWKSURL(server.URL, server.Client(), time.Hour)
+	j, _ := NewJWT[testClaims](JWTConfig{Keys: jwks})
+
+	for range 3 {
+		if _, err := j.Authenticate(bearerRequest(signToken(t, oldKey, validClaims()))); err != nil {
+			t.Fatalf("expected token to verify, got %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("expected the key set to be cached, got %d fetches", got)
+	}
+
+	document.Store(jwksDocument(t, oldKey, newKey))
+
+	// Unknown keys only trigger a reload once the minimum refresh interval has passed.
+	if _, err := j.Authenticate(bearerRequest(signToken(t, newKey, validClaims()))); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey before the refresh interval, got %v", err)
+	}
+
+	jwks.loadedAt = time.Now().Add(-jwksMinRefresh)
+	if _, err := j.Authenticate(bearerRequest(signToken(t, newKey, validClaims()))); err != nil {
+		t.Errorf("expected the rotated key to be picked up, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("expected one reload, got %d fetches", got)
+	}
+}
+
+func TestJWKSReloadInBackground(t *testing.T) {
+	keys := newSigningKeys(t)
+	document := jwksDocument(t, keys[1])
+
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			close(started)
+			<-release
+		}
+		_, _ = w.Write(document)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	jwks := NewJWKSURL(server.URL, server.Client(), time.Hour)
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatalf("expected key set to load, got %v", err)
+	}
+
+	// An expired key set is reloaded once while the cached keys are served.
+	jwks.loadedAt = time.Time{}
+	for range 3 {
+		if got, err := jwks.Keys(context.Background(), keys[1].kid); err != nil || len(got) != 1 {
+			t.Fatalf("expected the cached keys, got %v, %v", got, err)
+		}
+	}
+	<-started
+
+	// An unknown key waits for the reload in flight, up to its context.
+	jwks.mu.Lock()
+	jwks.loadedAt = time.Now().Add(-jwksMinRefresh)
+	jwks.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if got, err := jwks.Keys(ctx, "other"); err != nil || len(got) != 1 {
+		t.Errorf("expected the cached keys once the context is done, got %v, %v", got, err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("expected one reload, got %d fetches", got)
+	}
+}
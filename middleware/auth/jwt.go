@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken         = errors.New("auth: invalid token")
+	ErrTokenExpired         = errors.New("auth: token expired")
+	ErrTokenNotYetValid     = errors.New("auth: token not valid yet")
+	ErrInvalidIssuer        = errors.New("auth: invalid issuer")
+	ErrInvalidAudience      = errors.New("auth: invalid audience")
+	ErrUnsupportedAlgorithm = errors.New("auth: unsupported algorithm")
+	ErrUnknownKey           = errors.New("auth: unknown signing key")
+	ErrInvalidSignature     = errors.New("auth: invalid signature")
+	ErrNoKeys               = errors.New("auth: no verification keys")
+)
+
+// tokenErrors are the rejections a JWT challenge describes.
+var tokenErrors = []error{
+	ErrTokenExpired,
+	ErrTokenNotYetValid,
+	ErrInvalidIssuer,
+	ErrInvalidAudience,
+	ErrUnsupportedAlgorithm,
+	ErrUnknownKey,
+	ErrInvalidSignature,
+	ErrInvalidToken,
+}
+
+// Key is a token verification key: a []byte secret for HS256, an *rsa.PublicKey for
+// RS256, an *ecdsa.PublicKey on P-256 for ES256 or an ed25519.PublicKey for EdDSA.
+type Key struct {
+	ID        string
+	Algorithm string
+	Key       any
+}
+
+// KeySet provides the keys tokens are verified with. kid is the key a token asks for,
+// empty if it names none; a KeySet may reload when it does not know kid.
+type KeySet interface {
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a fixed KeySet.
+type StaticKeys []Key
+
+func (k StaticKeys) Keys(context.Context, string) ([]Key, error) {
+	return k, nil
+}
+
+// JWTConfig configures NewJWT.
+type JWTConfig struct {
+	Keys KeySet
+	// Algorithms restricts the accepted algorithms, all supported ones by default.
+	Algorithms []string
+	// Issuer and Audience are checked against iss and aud when set.
+	Issuer   string
+	Audience string
+	// Leeway is the clock skew allowed when checking exp and nbf.
+	Leeway time.Duration
+	// Realm is sent in the WWW-Authenticate challenge.
+	Realm string
+}
+
+// NumericDate is a JWT time, in seconds since the epoch.
+type NumericDate struct {
+	time.Time
+}
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("numeric date: %w", err)
+	}
+	if math.IsNaN(seconds) || seconds >= math.MaxInt64 || seconds < math.MinInt64 {
+		return fmt.Errorf("numeric date: %s out of range", data)
+	}
+	whole, fraction := math.Modf(seconds)
+	d.Time = time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+
+	return nil
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(d.Unix(), 10)), nil
+}
+
+// Audience is the aud claim, a string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// RegisteredClaims are the claims NewJWT validates; embed them in custom claims to read them.
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+// JWT authenticates bearer tokens, decoding their claims into T.
+type JWT[T any] struct {
+	config JWTConfig
+}
+
+// NewJWT creates an authenticator for signed bearer tokens. The principal's Claims hold
+// the token claims as T, see GetClaims.
+func NewJWT[T any](config JWTConfig) (*JWT[T], error) {
+	if config.Keys == nil {
+		return nil, ErrNoKeys
+	}
+	for _, alg := range config.Algorithms {
+		if !slices.Contains([]string{HS256, RS256, ES256, EdDSA}, alg) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+		}
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = []string{HS256, RS256, ES256, EdDSA}
+	}
+
+	return &JWT[T]{config: config}, nil
+}
+
+// JWTMiddleware is Middleware for NewJWT.
+func JWTMiddleware[T any](config JWTConfig) (gin.HandlerFunc, error) {
+	j, err := NewJWT[T](config)
+	if err != nil {
+		return nil, err
+	}
+
+	return Middleware(j), nil
+}
+
+func (j *JWT[T]) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	payload, err := j.verify(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	var registered RegisteredClaims
+	if err := json.Unmarshal(payload, &registered); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := j.validate(registered, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var claims T
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return &Principal{Scheme: "Bearer", Subject: registered.Subject, Claims: claims}, nil
+}
+
+// Challenge follows RFC 6750: no error for requests without a token, invalid_token otherwise.
+// Only the token errors of this package are described, never their details.
+func (j *JWT[T]) Challenge(err error) string {
+	if errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrUnavailable) {
+		return challenge("Bearer", "realm", j.config.Realm)
+	}
+
+	return challenge("Bearer", "realm", j.config.Realm, "error", "invalid_token",
+		"error_description", describe(err, tokenErrors))
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// verify checks the token signature and returns its decoded payload.
+func (j *JWT[T]) verify(ctx context.Context, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if !slices.Contains(j.config.Algorithms, header.Algorithm) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	keys, err := j.config.Keys.Keys(ctx, header.KeyID)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	found := false
+	for _, key := range keys {
+		if (header.KeyID != "" && key.ID != header.KeyID) || (key.Algorithm != "" && key.Algorithm != header.Algorithm) {
+			continue
+		}
+		if !keyFits(header.Algorithm, key.Key) {
+			continue
+		}
+
+		found = true
+		if verifySignature(header.Algorithm, key.Key, signed, signature) {
+			payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+			}
+
+			return payload, nil
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, header.KeyID)
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+func (j *JWT[T]) validate(claims RegisteredClaims, now time.Time) error {
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Add(j.config.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Add(j.config.Leeway).Before(claims.NotBefore.Time) {
+		return ErrTokenNotYetValid
+	}
+	if j.config.Issuer != "" && claims.Issuer != j.config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if j.config.Audience != "" && !slices.Contains(claims.Audience, j.config.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// keyFits reports whether key is of the type alg verifies with, so a token cannot pick an
+// algorithm that reinterprets a key, e.g. an RSA public key as an HMAC secret.
+func keyFits(alg string, key any) bool {
+	switch alg {
+	case HS256:
+		_, ok := key.([]byte)
+		return ok
+	case RS256:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case ES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve == elliptic.P256()
+	case EdDSA:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+
+	return false
+}
+
+func verifySignature(alg string, key any, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		if len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s)
+	case EdDSA:
+		return ed25519.Verify(key.(ed25519.PublicKey), signed, signature)
+	}
+
+	return false
+}
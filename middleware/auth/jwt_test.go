@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testClaims struct {
+	RegisteredClaims
+	Role string `json:"role"`
+}
+
+type signingKey struct {
+	alg     string
+	kid     string
+	private any
+	public  any
+}
+
+func newSigningKeys(t *testing.T) []signingKey {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	return []signingKey{
+		{alg: HS256, kid: "hs", private: secret, public: secret},
+		{alg: RS256, kid: "rs", private: rsaKey, public: &rsaKey.PublicKey},
+		{alg: ES256, kid: "es", private: ecKey, public: &ecKey.PublicKey},
+		{alg: EdDSA, kid: "ed", private: edPrivate, public: edPublic},
+	}
+}
+
+func signToken(t *testing.T, key signingKey, claims any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": key.alg, "kid": key.kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.private.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest[:])
+		signature, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), signErr
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func bearerRequest(token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req
+}
+
+func validClaims() testClaims {
+	return testClaims{
+		RegisteredClaims: RegisteredClaims{
+			Issuer:    "https://issuer.example.com",
+			Subject:   "user-1",
+			Audience:  Audience{"api"},
+			ExpiresAt: &NumericDate{time.Now().Add(time.Hour)},
+		},
+		Role: "admin",
+	}
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	keys := newSigningKeys(t)
+
+	var set StaticKeys
+	for _, key := range keys {
+		set = append(set, Key{ID: key.kid, Algorithm: key.alg, Key: key.public})
+	}
+
+	j, err := NewJWT[testClaims](JWTConfig{Keys: set, Issuer: "https://issuer.example.com", Audience: "api"})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	for _, key := range keys {
+		t.Run(key.alg, func(t *testing.T) {
+			principal, err := j.Authenticate(bearerRequest(signToken(t, key, validClaims())))
+			if err != nil {
+				t.Fatalf("expected token to be valid, got %v", err)
+			}
+
+			claims, ok := principal.Claims.(testClaims)
+			if !ok || claims.Role != "admin" || principal.Subject != "user-1" || principal.Scheme != "Bearer" {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestJWTValidation(t *testing.T) {
+	keys := newSigningKeys(t)
+	hs, rs := keys[0], keys[1]
+
+	j, err := NewJWT[testClaims](JWTConfig{
+		Keys:     StaticKeys{{ID: hs.kid, Key: hs.public}, {ID: rs.kid, Key: rs.public}},
+		Issuer:   "https://issuer.example.com",
+		Audience: "api",
+		Leeway:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	token := func(modify func(c *testClaims)) string {
+		claims := validClaims()
+		modify(&claims)
+		return signToken(t, rs, claims)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{name: "no token", token: "", expectedErr: ErrNoCredentials},
+		{name: "malformed", token: "abc", expectedErr: ErrInvalidToken},
+		{
+			name:        "expired",
+			token:       token(func(c *testClaims) { c.ExpiresAt = &NumericDate{time.Now().Add(-2 * time.Minute)} }),
+			expectedErr: ErrTokenExpired,
+		},
+		{
+			name:  "expires after 2262",
+			token: token(func(c *testClaims) { c.ExpiresAt = &NumericDate{time.Unix(9999999999, 0)} }),
+		},
+		{
+			name:  "expired within leeway",
+			token: token(func(c *testClaims) { c.ExpiresAt = &NumericDate{time.Now().Add(-30 * time.Second)} }),
+		},
+		{
+			name:        "not valid yet",
+			token:       token(func(c *testClaims) { c.NotBefore = &NumericDate{time.Now().Add(2 * time.Minute)} }),
+			expectedErr: ErrTokenNotYetValid,
+		},
+		{
+			name:        "wrong issuer",
+			token:       token(func(c *testClaims) { c.Issuer = "https://other.example.com" }),
+			expectedErr: ErrInvalidIssuer,
+		},
+		{
+			name:        "wrong audience",
+			token:       token(func(c *testClaims) { c.Audience = Audience{"web"} }),
+			expectedErr: ErrInvalidAudience,
+		},
+		{
+			name:        "tampered payload",
+			token:       tamper(token(func(c *testClaims) {})),
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name:        "unknown key",
+			token:       signToken(t, signingKey{alg: HS256, kid: "other", private: hs.private}, validClaims()),
+			expectedErr: ErrUnknownKey,
+		},
+		{
+			name:        "alg none",
+			token:       unsigned(validClaims()),
+			expectedErr: ErrUnsupportedAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.Authenticate(bearerRequest(tt.token))
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNumericDate(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected time.Time
+		invalid  bool
+	}{
+		{data: "1700000000", expected: time.Unix(1700000000, 0)},
+		{data: "1700000000.5", expected: time.Unix(1700000000, 5e8)},
+		{data: "9999999999", expected: time.Unix(9999999999, 0)},
+		{data: "1e19", invalid: true},
+		{data: `"1700000000"`, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var d NumericDate
+			err := d.UnmarshalJSON([]byte(tt.data))
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %v", d.Time)
+				}
+				return
+			}
+			if err != nil || !d.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v (%v)", tt.expected, d.Time, err)
+			}
+		})
+	}
+}
+
+func TestJWTAlgorithmConfusion(t *testing.T) {
+	rs := newSigningKeys(t)[1]
+
+	// An HS256 token keyed with the RSA public key must not verify against that key.
+	publicKey := rs.public.(*rsa.PublicKey)
+	forged := signingKey{alg: HS256, kid: rs.kid, private: publicKey.N.Bytes()}
+
+	j, err := NewJWT[testClaims](JWTConfig{Keys: StaticKeys{{ID: rs.kid, Key: rs.public}}})
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	if _, err := j.Authenticate(bearerRequest(signToken(t, forged, validClaims()))); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestNewJWTInvalidConfig(t *testing.T) {
+	if _, err := NewJWT[testClaims](JWTConfig{}); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys, got %v", err)
+	}
+	if _, err := NewJWT[testClaims](JWTConfig{Keys: StaticKeys{}, Algorithms: []string{"none"}}); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestJWTChallenge(t *testing.T) {
+	j, _ := NewJWT[testClaims](JWTConfig{Keys: StaticKeys{}, Realm: "api"})
+
+	if got := j.Challenge(ErrNoCredentials); got != `Bearer realm="api"` {
+		t.Errorf("unexpected challenge %q", got)
+	}
+	if got := j.Challenge(ErrTokenExpired); got != `Bearer realm="api", error="invalid_token", error_description="token expired"` {
+		t.Errorf("unexpected challenge %q", got)
+	}
+	if got := j.Challenge(fmt.Errorf("%w: %q", ErrUnknownKey, "k9")); got != `Bearer realm="api", error="invalid_token", error_description="unknown signing key"` {
+		t.Errorf("expected the details to be left out, got %q", got)
+	}
+	if got := j.Challenge(errors.New("decode claims: boom")); got != `Bearer realm="api", error="invalid_token"` {
+		t.Errorf("expected other errors not to be described, got %q", got)
+	}
+}
+
+type failingKeySet struct{}
+
+func (failingKeySet) Keys(context.Context, string) ([]Key, error) {
+	return nil, errors.New("load jwks: dial tcp 10.1.2.3:1: connection refused")
+}
+
+func TestJWTKeySetFailure(t *testing.T) {
+	hs := newSigningKeys(t)[0]
+	j, _ := NewJWT[testClaims](JWTConfig{Keys: failingKeySet{}, Realm: "api"})
+
+	_, err := j.Authenticate(bearerRequest(signToken(t, hs, validClaims())))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if got := j.Challenge(err); got != `Bearer realm="api"` {
+		t.Errorf("expected the key set failure not to be sent, got %q", got)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/test", Middleware(j), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, bearerRequest(signToken(t, hs, validClaims())))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("expected no challenge, got %q", got)
+	}
+	if strings.Contains(w.Body.String(), "10.1.2.3") {
+		t.Errorf("expected the key set failure not to be sent, got %s", w.Body.String())
+	}
+}
+
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	payload = []byte(strings.Replace(string(payload), `"admin"`, `"owner"`, 1))
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	return strings.Join(parts, ".")
+}
+
+func unsigned(claims any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(claims)
+
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
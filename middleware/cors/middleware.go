@@ -0,0 +1,283 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrWildcardWithCredentials = errors.New("cors: the * origin cannot be combined with credentials")
+
+// Config describes a CORS policy.
+type Config struct {
+	// AllowedOrigins lists exact origins like https://app.example.com, wildcard subdomains
+	// like https://*.example.com or * for any origin. Origins match regardless of case.
+	AllowedOrigins []string
+	// AllowedOriginPatterns are regular expressions matched against the whole origin,
+	// regardless of case like AllowedOrigins.
+	AllowedOriginPatterns []string
+	AllowedMethods        []string
+	// AllowedHeaders lists the request headers a preflight may ask for, * allows any.
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
+
+// DefaultConfig allows any origin without credentials.
+func DefaultConfig() Config {
+	return Config{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete,
+			http.MethodPatch, http.MethodHead, http.MethodOptions,
+		},
+		AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
+	}
+}
+
+// Policy is a validated Config.
+type Policy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	subdomains       []subdomainOrigin
+	patterns         []*regexp.Regexp
+	methods          []string
+	anyHeader        bool
+	headers          map[string]bool
+	allowHeaders     string
+	exposeHeaders    string
+	maxAge           string
+	allowCredentials bool
+}
+
+type subdomainOrigin struct {
+	scheme string
+	domain string
+}
+
+func NewPolicy(config Config) (*Policy, error) {
+	p := &Policy{
+		origins:          make(map[string]bool),
+		headers:          make(map[string]bool),
+		exposeHeaders:    strings.Join(config.ExposedHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		scheme, host, _ := strings.Cut(origin, "://")
+
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.HasPrefix(host, "*."):
+			p.subdomains = append(p.subdomains, subdomainOrigin{scheme: scheme + "://", domain: host[1:]})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	if p.anyOrigin && p.allowCredentials {
+		return nil, ErrWildcardWithCredentials
+	}
+
+	for _, pattern := range config.AllowedOriginPatterns {
+		re, err := regexp.Compile("(?i)^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("cors: origin pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+
+	for _, method := range config.AllowedMethods {
+		p.methods = append(p.methods, strings.ToUpper(method))
+	}
+
+	for _, header := range config.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	p.allowHeaders = strings.Join(config.AllowedHeaders, ", ")
+
+	if config.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(config.MaxAge / time.Second))
+	}
+
+	return p, nil
+}
+
+// New builds a middleware enforcing config, e.g. for WithCorsMiddleware.
+func New(config Config) (gin.HandlerFunc, error) {
+	p, err := NewPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Middleware(), nil
+}
+
+// DefaultPolicy is the policy of DefaultConfig.
+func DefaultPolicy() *Policy {
+	p, _ := NewPolicy(DefaultConfig())
+
+	return p
+}
+
+// Default is the middleware of DefaultConfig.
+func Default() gin.HandlerFunc {
+	return DefaultPolicy().Middleware()
+}
+
+// Middleware answers preflight requests and adds CORS headers to the other requests
+// from allowed origins. Requests from other origins get no CORS headers, so browsers block them.
+func (p *Policy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsPreflight(c.Request) {
+			p.Preflight(c, p.methods)
+			return
+		}
+
+		p.Apply(c)
+		c.Next()
+	}
+}
+
+// IsPreflight reports whether r is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Apply adds the CORS headers of an actual request and reports whether its origin is allowed.
+func (p *Policy) Apply(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if !p.anyOrigin || p.allowCredentials {
+		c.Writer.Header().Add("Vary", "Origin")
+	}
+	if origin == "" || !p.allowsOrigin(origin) {
+		return false
+	}
+
+	p.setOrigin(c, origin)
+	if p.exposeHeaders != "" {
+		c.Header("Access-Control-Expose-Headers", p.exposeHeaders)
+	}
+
+	return true
+}
+
+// Preflight answers a preflight request, accepting the requested method only if it is
+// one of methods, e.g. the methods routed for the path, and of AllowedMethods when set.
+// Preflights that are not allowed get 403 Forbidden.
+func (p *Policy) Preflight(c *gin.Context, methods []string) {
+	if len(p.methods) > 0 {
+		methods = slices.DeleteFunc(slices.Clone(methods), func(method string) bool {
+			return !slices.Contains(p.methods, method)
+		})
+	}
+
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := c.GetHeader("Origin")
+	method := c.GetHeader("Access-Control-Request-Method")
+	requested := requestedHeaders(c.Request)
+
+	if !p.allowsOrigin(origin) || !slices.Contains(methods, method) || !p.allowsHeaders(requested) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	p.setOrigin(c, origin)
+	c.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if p.anyHeader {
+		if len(requested) > 0 {
+			c.Header("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+	} else if p.allowHeaders != "" {
+		c.Header("Access-Control-Allow-Headers", p.allowHeaders)
+	}
+	if p.maxAge != "" {
+		c.Header("Access-Control-Max-Age", p.maxAge)
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (p *Policy) setOrigin(c *gin.Context, origin string) {
+	if p.anyOrigin && !p.allowCredentials {
+		c.Header("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	c.Header("Access-Control-Allow-Origin", origin)
+	if p.allowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+
+	for _, subdomain := range p.subdomains {
+		host, ok := strings.CutPrefix(origin, subdomain.scheme)
+		if ok && len(host) > len(subdomain.domain) && strings.HasSuffix(host, subdomain.domain) {
+			return true
+		}
+	}
+
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) allowsHeaders(headers []string) bool {
+	if p.anyHeader {
+		return true
+	}
+
+	for _, header := range headers {
+		if !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+	}
+
+	return headers
+}
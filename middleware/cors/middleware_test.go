@@ -0,0 +1,242 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRouter(t *testing.T, config Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	middleware, err := New(config)
+	if err != nil {
+		t.Fatalf("expected config to be valid, got %v", err)
+	}
+
+	router := gin.New()
+	router.Use(middleware)
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func serve(router *gin.Engine, method string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "/test", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestNewPolicyInvalidConfig(t *testing.T) {
+	_, err := NewPolicy(Config{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if !errors.Is(err, ErrWildcardWithCredentials) {
+		t.Errorf("expected ErrWildcardWithCredentials, got %v", err)
+	}
+
+	if _, err := NewPolicy(Config{AllowedOriginPatterns: []string{"https://(.*"}}); err == nil {
+		t.Error("expected malformed origin pattern to be rejected")
+	}
+}
+
+func TestMiddlewareOrigins(t *testing.T) {
+	router := newRouter(t, Config{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []string{`https://preview-\d+\.example\.net`, `https://Staging\.example\.net`},
+		AllowedMethods:        []string{http.MethodGet},
+		ExposedHeaders:        []string{"X-Request-Id"},
+		AllowCredentials:      true,
+	})
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "exact origin", origin: "https://app.example.com", allowed: true},
+		{name: "exact origin is case insensitive", origin: "https://App.Example.com", allowed: true},
+		{name: "wildcard subdomain", origin: "https://api.example.org", allowed: true},
+		{name: "nested wildcard subdomain", origin: "https://eu.api.example.org", allowed: true},
+		{name: "wildcard does not match the bare domain", origin: "https://example.org", allowed: false},
+		{name: "wildcard does not match another scheme", origin: "http://api.example.org", allowed: false},
+		{name: "wildcard does not match a suffix", origin: "https://evilexample.org", allowed: false},
+		{name: "regex pattern", origin: "https://preview-42.example.net", allowed: true},
+		{name: "regex pattern is case insensitive", origin: "https://staging.example.net", allowed: true},
+		{name: "regex pattern ignores the case of the origin", origin: "https://PREVIEW-7.example.net", allowed: true},
+		{name: "regex pattern is anchored", origin: "https://preview-42.example.net.evil.com", allowed: false},
+		{name: "unknown origin", origin: "https://evil.com", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodGet, map[string]string{"Origin": tt.origin})
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if got := w.Header().Get("Vary"); got != "Origin" {
+				t.Errorf("expected Vary Origin, got %q", got)
+			}
+
+			got := w.Header().Get("Access-Control-Allow-Origin")
+			if !tt.allowed {
+				if got != "" {
+					t.Errorf("expected no Access-Control-Allow-Origin, got %q", got)
+				}
+				return
+			}
+
+			if got != tt.origin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.origin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("expected Access-Control-Allow-Credentials true, got %q", got)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+				t.Errorf("expected Access-Control-Expose-Headers X-Request-Id, got %q", got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAnyOrigin(t *testing.T) {
+	router := newRouter(t, DefaultConfig())
+
+	w := serve(router, http.MethodGet, map[string]string{"Origin": "https://app.example.com"})
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected Access-Control-Allow-Origin *, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Credentials, got %q", got)
+	}
+
+	w = serve(router, http.MethodGet, nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers without Origin, got %q", got)
+	}
+}
+
+func TestMiddlewarePreflight(t *testing.T) {
+	router := newRouter(t, Config{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		MaxAge:           10 * time.Minute,
+		AllowCredentials: true,
+	})
+
+	tests := []struct {
+		name           string
+		origin         string
+		method         string
+		headers        string
+		expectedStatus int
+	}{
+		{
+			name:           "allowed preflight",
+			origin:         "https://app.example.com",
+			method:         http.MethodPost,
+			headers:        "content-type, authorization",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "disallowed origin",
+			origin:         "https://evil.com",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "disallowed method",
+			origin:         "https://app.example.com",
+			method:         http.MethodDelete,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "disallowed header",
+			origin:         "https://app.example.com",
+			method:         http.MethodPost,
+			headers:        "Content-Type, X-Debug",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodOptions, map[string]string{
+				"Origin":                         tt.origin,
+				"Access-Control-Request-Method":  tt.method,
+				"Access-Control-Request-Headers": tt.headers,
+			})
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if len(w.Header().Values("Vary")) != 3 {
+				t.Errorf("expected Vary on the origin and the request headers, got %v", w.Header().Values("Vary"))
+			}
+			if tt.expectedStatus != http.StatusNoContent {
+				if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+					t.Errorf("expected no Access-Control-Allow-Origin, got %q", got)
+				}
+				return
+			}
+
+			expected := map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+				"Access-Control-Max-Age":           "600",
+			}
+			for header, value := range expected {
+				if got := w.Header().Get(header); got != value {
+					t.Errorf("expected %s %q, got %q", header, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewarePreflightAnyHeader(t *testing.T) {
+	router := newRouter(t, Config{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet},
+		AllowedHeaders: []string{"*"},
+	})
+
+	w := serve(router, http.MethodOptions, map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  http.MethodGet,
+		"Access-Control-Request-Headers": "X-Debug",
+	})
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "X-Debug" {
+		t.Errorf("expected the requested headers to be allowed, got %q", got)
+	}
+}
+
+func TestMiddlewareOptionsWithoutPreflight(t *testing.T) {
+	router := newRouter(t, DefaultConfig())
+	router.OPTIONS("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := serve(router, http.MethodOptions, map[string]string{"Origin": "https://app.example.com"})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected plain OPTIONS to reach the route, got %d", w.Code)
+	}
+}
@@ -37,3 +37,8 @@ func Fail(c *gin.Context, code int, message string) {
 
 	c.JSON(httpCode, Envelope{Error: &ErrorResponse{Code: code, Message: message}})
 }
+
+// Abort stops the handler chain and responds with status and an error envelope whose code is the status.
+func Abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, Envelope{Error: &ErrorResponse{Code: status, Message: message}})
+}
@@ -217,6 +217,57 @@ func TestFail(t *testing.T) {
 	}
 }
 
+func TestAbort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		status  int
+		message string
+	}{
+		{
+			name:    "not acceptable",
+			status:  http.StatusNotAcceptable,
+			message: "Unsupported API version",
+		},
+		{
+			name:    "method not allowed",
+			status:  http.StatusMethodNotAllowed,
+			message: "Method not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			Abort(c, tt.status, tt.message)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if !c.IsAborted() {
+				t.Error("expected handler chain to be aborted")
+			}
+
+			var response Envelope
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+
+			if response.Error == nil {
+				t.Fatal("expected error to be set")
+			}
+
+			if response.Error.Code != tt.status || response.Error.Message != tt.message {
+				t.Errorf("unexpected error %+v", response.Error)
+			}
+		})
+	}
+}
+
 func TestEnvelopeStructure(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrFrozen = errors.New("routes are frozen, register them before the server starts listening")
+
+// Registrar is the registration surface a Module mounts its handlers through.
+type Registrar interface {
+	RegisterHandlers(handlers ...Handler) error
+	RegisterVersion(version string, handlers ...Handler) error
+}
+
+// Module is an independently registered part of the API, e.g. billing or users.
+type Module interface {
+	// Name identifies the module; a module is registered once per name.
+	Name() string
+	Register(r Registrar) error
+}
+
+// RegisterModule registers each module not registered yet. A failing module does not stop
+// the others; all errors are returned joined.
+func (s *TransportServer) RegisterModule(modules ...Module) error {
+	var errs []error
+
+	for _, module := range modules {
+		s.routesMu.Lock()
+		frozen := s.frozen
+		_, registered := s.modules[module.Name()]
+		s.routesMu.Unlock()
+
+		if frozen {
+			return errors.Join(append(errs, ErrFrozen)...)
+		}
+		if registered {
+			continue
+		}
+
+		if err := module.Register(s); err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", module.Name(), err))
+			continue
+		}
+
+		s.routesMu.Lock()
+		if s.modules == nil {
+			s.modules = make(map[string]struct{})
+		}
+		s.modules[module.Name()] = struct{}{}
+		s.routesMu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Freeze ends registration: later RegisterHandlers, RegisterVersion and RegisterModule
+// calls return ErrFrozen. Listen and StartWithListener freeze the routes automatically.
+func (s *TransportServer) Freeze() {
+	s.routesMu.Lock()
+	s.frozen = true
+	s.routesMu.Unlock()
+}
+
+type registeredHandler struct {
+	version string
+	handler Handler
+}
+
+// unregisteredHandlers drops the handlers already registered for version, including
+// repeats within handlers.
+func (s *TransportServer) unregisteredHandlers(version string, handlers []Handler) []Handler {
+	seen := make([]Handler, 0, len(handlers))
+	for _, r := range s.registered {
+		if r.version == version {
+			seen = append(seen, r.handler)
+		}
+	}
+
+	fresh := make([]Handler, 0, len(handlers))
+	for _, handler := range handlers {
+		if containsHandler(seen, handler) {
+			continue
+		}
+		seen = append(seen, handler)
+		fresh = append(fresh, handler)
+	}
+
+	return fresh
+}
+
+// containsHandler reports whether handler is among handlers. Handlers that cannot be
+// compared, e.g. structs holding a slice, match when they have the same type and routes.
+func containsHandler(handlers []Handler, handler Handler) bool {
+	t := reflect.TypeOf(handler)
+	if t == nil {
+		return false
+	}
+
+	for _, h := range handlers {
+		if reflect.TypeOf(h) != t {
+			continue
+		}
+		if t.Comparable() {
+			if h == handler {
+				return true
+			}
+			continue
+		}
+		if routeSignature(h) == routeSignature(handler) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routeSignature lists the methods, URIs and versions handler routes.
+func routeSignature(handler Handler) string {
+	routes, _ := flattenRoutes(handler)
+
+	var b strings.Builder
+	for _, route := range routes {
+		fmt.Fprintf(&b, "%s %s %v\n", route.Method, route.Uri, route.Versions)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testModule struct {
+	name     string
+	handlers []Handler
+	err      error
+	calls    int
+}
+
+func (m *testModule) Name() string {
+	return m.name
+}
+
+func (m *testModule) Register(r Registrar) error {
+	m.calls++
+	if m.err != nil {
+		return m.err
+	}
+
+	return r.RegisterHandlers(m.handlers...)
+}
+
+func routeHandler(uri string) *mockHandler {
+	return &mockHandler{
+		routes: []Route{
+			{
+				Uri:    uri,
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, uri)
+				},
+			},
+		},
+	}
+}
+
+func expectStatus(t *testing.T, server *TransportServer, path string, expected int) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	server.engine.ServeHTTP(w, req)
+
+	if w.Code != expected {
+		t.Errorf("expected status %d for %s, got %d", expected, path, w.Code)
+	}
+}
+
+func TestTransportServerIncrementalRegistration(t *testing.T) {
+	users := routeHandler("/users")
+	orders := routeHandler("/orders")
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+
+	for _, handlers := range [][]Handler{{users}, {orders}, {users, orders}, {users, users}} {
+		if err := server.RegisterHandlers(handlers...); err != nil {
+			t.Fatalf("expected registration to succeed, got %v", err)
+		}
+	}
+
+	// GET and its HEAD twin for each route.
+	if routes := server.engine.Routes(); len(routes) != 4 {
+		t.Errorf("expected 4 mounted routes, got %d", len(routes))
+	}
+	if len(server.groups) != 1 {
+		t.Errorf("expected the version groups to be created once, got %d", len(server.groups))
+	}
+
+	expectStatus(t, server, "/api/v1/users", http.StatusOK)
+	expectStatus(t, server, "/api/v1/orders", http.StatusOK)
+	expectStatus(t, server, "/api/v1/missing", http.StatusNotFound)
+}
+
+// valueHandler is not comparable, so repeats are recognised by its routes.
+type valueHandler struct {
+	routes []Route
+}
+
+func (h valueHandler) GetRoutes() []Route {
+	return h.routes
+}
+
+func TestTransportServerIncrementalRegistrationByValue(t *testing.T) {
+	handler := func(uri string) valueHandler {
+		return valueHandler{routes: routeHandler(uri).routes}
+	}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+
+	for _, handlers := range [][]Handler{{handler("/a")}, {handler("/a")}, {handler("/a"), handler("/b")}} {
+		if err := server.RegisterHandlers(handlers...); err != nil {
+			t.Fatalf("expected registration to succeed, got %v", err)
+		}
+	}
+
+	if routes := server.engine.Routes(); len(routes) != 4 {
+		t.Errorf("expected 4 mounted routes, got %d", len(routes))
+	}
+	expectStatus(t, server, "/api/v1/a", http.StatusOK)
+	expectStatus(t, server, "/api/v1/b", http.StatusOK)
+}
+
+func TestTransportServerRegisterModule(t *testing.T) {
+	users := &testModule{name: "users", handlers: []Handler{routeHandler("/users")}}
+	billing := &testModule{name: "billing", err: errors.New("missing payment provider")}
+	orders := &testModule{name: "orders", handlers: []Handler{routeHandler("/orders")}}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+
+	err := server.RegisterModule(users, billing, orders)
+	if err == nil || !strings.Contains(err.Error(), "module billing: missing payment provider") {
+		t.Errorf("expected the billing module error, got %v", err)
+	}
+
+	if err := server.RegisterModule(users, orders); err != nil {
+		t.Errorf("expected registering modules again to be a no-op, got %v", err)
+	}
+	if users.calls != 1 || orders.calls != 1 {
+		t.Errorf("expected each module to be registered once, got users=%d orders=%d", users.calls, orders.calls)
+	}
+
+	expectStatus(t, server, "/api/v1/users", http.StatusOK)
+	expectStatus(t, server, "/api/v1/orders", http.StatusOK)
+}
+
+func TestTransportServerFreeze(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(routeHandler("/users")); err != nil {
+		t.Fatalf("expected registration to succeed, got %v", err)
+	}
+
+	server.Freeze()
+
+	if err := server.RegisterHandlers(routeHandler("/orders")); !errors.Is(err, ErrFrozen) {
+		t.Errorf("expected ErrFrozen, got %v", err)
+	}
+	if err := server.RegisterModule(&testModule{name: "orders"}); !errors.Is(err, ErrFrozen) {
+		t.Errorf("expected ErrFrozen, got %v", err)
+	}
+
+	expectStatus(t, server, "/api/v1/users", http.StatusOK)
+	expectStatus(t, server, "/api/v1/orders", http.StatusNotFound)
+}
+
+func TestTransportServerListenFreezes(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST), WithHost("127.0.0.1"), WithPort(0))
+	if err := server.Listen(); err != nil {
+		t.Fatalf("expected listen to succeed, got %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	if err := server.RegisterVersion("v2", routeHandler("/users")); !errors.Is(err, ErrFrozen) {
+		t.Errorf("expected ErrFrozen after Listen, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+const HeaderAPIVersion = "X-API-Version"
+
+type apiVersionKey struct{}
+
+var apiVersionKeyCtx = apiVersionKey{}
+
+// APIVersion returns the API version the request is served under, e.g. "v2".
+func APIVersion(c *gin.Context) string {
+	return c.GetString(apiVersionKeyCtx)
+}
+
+func setAPIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKeyCtx, version)
+		c.Next()
+	}
+}
+
+// negotiateVersion dispatches a request to an unversioned path, e.g. /api/users, to the
+// version selected by its headers. It reports whether the request has been handled.
+func (s *TransportServer) negotiateVersion(c *gin.Context) bool {
+	base, rest, ok := s.unversionedPath(c)
+	if !ok {
+		return false
+	}
+
+	version, ok := requestedVersion(c.Request, s.cfg.versionVendor)
+	if !ok {
+		version = defaultAPIVersion
+	}
+
+	s.mu.RLock()
+	_, registered := s.versions[version]
+	s.mu.RUnlock()
+
+	if !registered {
+		response.Abort(c, http.StatusNotAcceptable, "Unsupported API version "+version)
+		return true
+	}
+
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), apiVersionKeyCtx, version))
+	c.Request.URL.Path = path.Join(base, version, rest)
+	c.Request.URL.RawPath = ""
+	s.engine.HandleContext(c)
+
+	return true
+}
+
+// unversionedPath splits a path under the base path for negotiateVersion, unless
+// negotiation is off or the request has been dispatched already.
+func (s *TransportServer) unversionedPath(c *gin.Context) (base, rest string, ok bool) {
+	if s.cfg.versionVendor == "" || c.Request.Context().Value(apiVersionKeyCtx) != nil {
+		return "", "", false
+	}
+
+	base = path.Join("/", s.cfg.basePath)
+	rest, ok = strings.CutPrefix(c.Request.URL.Path, base)
+	if !ok || (rest != "" && base != "/" && rest[0] != '/') {
+		return "", "", false
+	}
+
+	return base, rest, true
+}
+
+// requestedVersion reads the version from the Accept media type like
+// application/vnd.<vendor>.v2+json with the highest q, falling back to the X-API-Version
+// header. Media types with q=0 are refused by the client and never chosen.
+func requestedVersion(r *http.Request, vendor string) (string, bool) {
+	prefix := "application/vnd." + vendor + "."
+	best, bestQ := "", 0.0
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaType)
+			if err != nil {
+				continue
+			}
+
+			version, ok := strings.CutPrefix(mediaType, prefix)
+			if !ok {
+				continue
+			}
+			version, _, _ = strings.Cut(version, "+")
+
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(value, 64); err != nil {
+					continue
+				}
+			}
+			if version != "" && q > bestQ {
+				best, bestQ = version, q
+			}
+		}
+	}
+
+	if best != "" {
+		return normalizeVersion(best), true
+	}
+
+	if version := strings.TrimSpace(r.Header.Get(HeaderAPIVersion)); version != "" {
+		return normalizeVersion(version), true
+	}
+
+	return "", false
+}
+
+// normalizeVersion turns "2" into "v2" so both header styles name the same version.
+func normalizeVersion(version string) string {
+	version = strings.ToLower(version)
+	if strings.Trim(version, "0123456789") == "" {
+		return "v" + version
+	}
+
+	return version
+}
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestedVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		headers         map[string]string
+		expectedVersion string
+		expectedOK      bool
+	}{
+		{
+			name:            "vendor media type",
+			headers:         map[string]string{"Accept": "application/vnd.acme.v2+json"},
+			expectedVersion: "v2",
+			expectedOK:      true,
+		},
+		{
+			name:            "vendor media type among others",
+			headers:         map[string]string{"Accept": "text/html, application/vnd.acme.v3+json; q=0.9"},
+			expectedVersion: "v3",
+			expectedOK:      true,
+		},
+		{
+			name:            "refused media type",
+			headers:         map[string]string{"Accept": "application/vnd.acme.v2+json; q=0, application/vnd.acme.v1+json"},
+			expectedVersion: "v1",
+			expectedOK:      true,
+		},
+		{
+			name:            "highest q wins",
+			headers:         map[string]string{"Accept": "application/vnd.acme.v1+json; q=0.5, application/vnd.acme.v2+json; q=0.8"},
+			expectedVersion: "v2",
+			expectedOK:      true,
+		},
+		{
+			name:            "refused media type falls back to the header",
+			headers:         map[string]string{"Accept": "application/vnd.acme.v3+json; q=0", HeaderAPIVersion: "v2"},
+			expectedVersion: "v2",
+			expectedOK:      true,
+		},
+		{
+			name:       "other vendor",
+			headers:    map[string]string{"Accept": "application/vnd.other.v2+json"},
+			expectedOK: false,
+		},
+		{
+			name:            "version header",
+			headers:         map[string]string{HeaderAPIVersion: "v2"},
+			expectedVersion: "v2",
+			expectedOK:      true,
+		},
+		{
+			name:            "numeric version header",
+			headers:         map[string]string{HeaderAPIVersion: "2"},
+			expectedVersion: "v2",
+			expectedOK:      true,
+		},
+		{
+			name: "media type wins over header",
+			headers: map[string]string{
+				"Accept":         "application/vnd.acme.v3+json",
+				HeaderAPIVersion: "v2",
+			},
+			expectedVersion: "v3",
+			expectedOK:      true,
+		},
+		{
+			name:       "no version",
+			headers:    map[string]string{"Accept": "application/json"},
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			version, ok := requestedVersion(req, "acme")
+			if ok != tt.expectedOK || version != tt.expectedVersion {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.expectedVersion, tt.expectedOK, version, ok)
+			}
+		})
+	}
+}
+
+func TestTransportServerVersionNegotiation(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithVersionNegotiation("acme"),
+	)
+
+	reportVersion := func(prefix string) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			c.String(http.StatusOK, prefix+":"+APIVersion(c))
+		}
+	}
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{Uri: "/users", Method: http.MethodGet, Versions: []string{"v1", "v2"}, Handler: reportVersion("users")},
+			{Uri: "/orders", Method: http.MethodGet, Handler: reportVersion("orders")},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+	if err := server.RegisterVersion("v3", &mockHandler{
+		routes: []Route{
+			{Uri: "/users", Method: http.MethodGet, Handler: reportVersion("users-next")},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		headers        map[string]string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "path version",
+			path:           "/api/v2/users",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "users:v2",
+		},
+		{
+			name:           "accept header",
+			path:           "/api/users",
+			headers:        map[string]string{"Accept": "application/vnd.acme.v3+json"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "users-next:v3",
+		},
+		{
+			name:           "version header",
+			path:           "/api/users",
+			headers:        map[string]string{HeaderAPIVersion: "2"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "users:v2",
+		},
+		{
+			name:           "default version",
+			path:           "/api/orders",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "orders:v1",
+		},
+		{
+			name:           "route missing in negotiated version",
+			path:           "/api/orders",
+			headers:        map[string]string{HeaderAPIVersion: "v2"},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown version",
+			path:           "/api/users",
+			headers:        map[string]string{"Accept": "application/vnd.acme.v9+json"},
+			expectedStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:           "outside base path",
+			path:           "/users",
+			headers:        map[string]string{HeaderAPIVersion: "v2"},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			server.engine.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("not acceptable envelope", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set(HeaderAPIVersion, "v9")
+		server.engine.ServeHTTP(w, req)
+
+		var envelope response.Envelope
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if envelope.Error == nil || envelope.Error.Code != http.StatusNotAcceptable {
+			t.Errorf("expected 406 error envelope, got %s", w.Body.String())
+		}
+	})
+}
+
+func TestTransportServerVersionNegotiationDisabled(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/ping", nil)
+	req.Header.Set(HeaderAPIVersion, "v1")
+	server.engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
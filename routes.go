@@ -0,0 +1,369 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"github.com/elfingit/gin-utils/middleware/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrUnknownMethod  = errors.New("unknown HTTP method")
+	ErrNilHandler     = errors.New("route has no handler")
+	ErrInvalidURI     = errors.New("invalid route URI")
+	ErrDuplicateRoute = errors.New("duplicate route")
+	ErrRouteConflict  = errors.New("conflicting wildcard route")
+	ErrInvalidGroup   = errors.New("route group cannot have a method or handler")
+
+	ErrDuplicateRouteName = errors.New("duplicate route name")
+)
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// anyMethods are the methods gin's RouterGroup.Any registers.
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodHead,
+	http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// routeEntry is a route resolved to a single method and absolute path.
+type routeEntry struct {
+	handler Handler
+	route   Route
+	version string
+	method  string
+	path    string
+	// autoHead marks the HEAD twin mounted for a GET route.
+	autoHead bool
+}
+
+func (e routeEntry) String() string {
+	owner := "registered route"
+	if e.handler != nil {
+		owner = fmt.Sprintf("%T", e.handler)
+	}
+	if e.autoHead {
+		owner = "automatic HEAD of the GET route of " + owner
+	}
+
+	return fmt.Sprintf("%s %s (%s)", e.method, e.path, owner)
+}
+
+// routeTable resolves the routes of handlers registered under version and checks them
+// against each other and the routes already mounted. Every problem found is reported.
+func (s *TransportServer) routeTable(version string, handlers []Handler) ([]routeEntry, error) {
+	var entries []routeEntry
+	var errs []error
+	explicitHead := make(map[string]bool)
+	names := make(map[string]bool)
+
+	for _, handler := range handlers {
+		routes, groupErrs := flattenRoutes(handler)
+		errs = append(errs, groupErrs...)
+
+		for _, route := range routes {
+			route.Method = strings.ToUpper(route.Method)
+			if len(route.Versions) == 0 {
+				route.Versions = []string{version}
+			}
+
+			if err := errors.Join(checkRoute(route), s.checkAuth(route.Auth)); err != nil {
+				errs = append(errs, fmt.Errorf("%T: route %s %s: %w", handler, route.Method, route.Uri, err))
+				continue
+			}
+
+			if route.Name != "" {
+				if _, taken := s.names[route.Name]; taken || names[route.Name] {
+					errs = append(errs, fmt.Errorf("%T: route %s %s: %w: %s", handler, route.Method, route.Uri, ErrDuplicateRouteName, route.Name))
+				}
+				names[route.Name] = true
+			}
+
+			methods := []string{route.Method}
+			if route.Method == MethodAny {
+				methods = anyMethods
+			}
+
+			for _, v := range route.Versions {
+				for _, method := range methods {
+					entry := routeEntry{
+						handler: handler,
+						route:   route,
+						version: v,
+						method:  method,
+						path:    joinRoutePath(path.Join("/", s.cfg.basePath, v), route.Uri),
+					}
+					entries = append(entries, entry)
+
+					if method == http.MethodHead {
+						explicitHead[entry.path] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.method == http.MethodGet && !explicitHead[entry.path] {
+			head := entry
+			head.method = http.MethodHead
+			head.autoHead = true
+			entries = append(entries, head)
+		}
+	}
+
+	// Routes mounted by RegisterVersion keep their owner for the error messages.
+	known := make(map[string]routeEntry, len(s.routes))
+	for _, entry := range s.routes {
+		known[entry.method+" "+entry.path] = entry
+	}
+
+	registered := make(map[string][]routeEntry)
+	for _, info := range s.engine.Routes() {
+		entry, ok := known[info.Method+" "+info.Path]
+		if !ok {
+			entry = routeEntry{method: info.Method, path: info.Path}
+		}
+		registered[info.Method] = append(registered[info.Method], entry)
+	}
+
+	mounted := make([]routeEntry, 0, len(entries))
+	for _, entry := range entries {
+		if err := findClash(entry, registered[entry.method]); err != nil {
+			// A GET route simply does not answer HEAD where another route already does.
+			if !entry.autoHead {
+				errs = append(errs, fmt.Errorf("%T: route %s %s: %w", entry.handler, entry.method, entry.route.Uri, err))
+			}
+			continue
+		}
+
+		registered[entry.method] = append(registered[entry.method], entry)
+		mounted = append(mounted, entry)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return mounted, nil
+}
+
+func findClash(entry routeEntry, registered []routeEntry) error {
+	for _, other := range registered {
+		if entry.path == other.path {
+			return fmt.Errorf("%w: %s", ErrDuplicateRoute, other)
+		}
+		if pathsClash(entry.path, other.path) {
+			return fmt.Errorf("%w: %s", ErrRouteConflict, other)
+		}
+	}
+
+	return nil
+}
+
+// flattenRoutes resolves the prefix of a GroupedHandler and nested route groups into
+// plain routes with absolute URIs and the group middlewares prepended.
+func flattenRoutes(handler Handler) ([]Route, []error) {
+	group := Route{Routes: handler.GetRoutes()}
+	if grouped, ok := handler.(GroupedHandler); ok {
+		group.Uri = grouped.Prefix()
+		group.Middlewares = grouped.Middlewares()
+	}
+
+	var routes []Route
+	var errs []error
+
+	var walk func(parent Route)
+	walk = func(parent Route) {
+		for _, route := range parent.Routes {
+			route.Uri = joinRoutePath(parent.Uri, route.Uri)
+			route.Middlewares = append(append([]gin.HandlerFunc{}, parent.Middlewares...), route.Middlewares...)
+			route.IsAuthProtected = route.IsAuthProtected || parent.IsAuthProtected
+			if len(route.Versions) == 0 {
+				route.Versions = parent.Versions
+			}
+			if route.Auth == nil {
+				route.Auth = parent.Auth
+			}
+			if route.CORS == nil {
+				route.CORS = parent.CORS
+			}
+
+			if len(route.Routes) == 0 {
+				routes = append(routes, route)
+				continue
+			}
+
+			if route.Method != "" || route.Handler != nil {
+				errs = append(errs, fmt.Errorf("%T: route group %s: %w", handler, route.Uri, ErrInvalidGroup))
+				continue
+			}
+			walk(route)
+		}
+	}
+	walk(group)
+
+	return routes, errs
+}
+
+func checkRoute(route Route) error {
+	if route.Method != MethodAny && !knownMethods[route.Method] {
+		return ErrUnknownMethod
+	}
+	if route.Handler == nil {
+		return ErrNilHandler
+	}
+
+	return checkURI(route.Uri)
+}
+
+// checkURI rejects paths gin's router would panic on or silently mangle.
+func checkURI(uri string) error {
+	if uri == "" {
+		return nil
+	}
+	if uri[0] != '/' {
+		return fmt.Errorf("%w: must start with /", ErrInvalidURI)
+	}
+	if strings.IndexFunc(uri, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("%w: contains whitespace", ErrInvalidURI)
+	}
+
+	segments := strings.Split(uri, "/")
+	for i, segment := range segments {
+		if strings.Count(segment, ":")+strings.Count(segment, "*") > 1 {
+			return fmt.Errorf("%w: only one wildcard per path segment is allowed", ErrInvalidURI)
+		}
+
+		wildcard := strings.IndexAny(segment, ":*")
+		if wildcard < 0 {
+			continue
+		}
+		if wildcard == len(segment)-1 {
+			return fmt.Errorf("%w: wildcards must be named", ErrInvalidURI)
+		}
+		if segment[wildcard] == '*' && (wildcard != 0 || i != len(segments)-1) {
+			return fmt.Errorf("%w: catch-all must be the last path segment", ErrInvalidURI)
+		}
+	}
+
+	return nil
+}
+
+// joinRoutePath joins like gin's RouterGroup, keeping the trailing slash of uri.
+func joinRoutePath(base, uri string) string {
+	if uri == "" {
+		return base
+	}
+
+	joined := path.Join(base, uri)
+	if strings.HasSuffix(uri, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+
+	return joined
+}
+
+// pathsClash reports whether gin's router refuses to hold both paths in one method tree:
+// differently named parameters at the same position, or a catch-all next to anything else.
+func pathsClash(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+
+		aWild, bWild := wildcardKind(as[i]), wildcardKind(bs[i])
+		switch {
+		case aWild == '*' || bWild == '*':
+			return true
+		case aWild == ':' && bWild == ':':
+			return true
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+func wildcardKind(segment string) byte {
+	if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+		return segment[0]
+	}
+
+	return 0
+}
+
+// RouteInfo describes a mounted route.
+type RouteInfo struct {
+	Name            string   `json:"name,omitempty"`
+	Method          string   `json:"method"`
+	Path            string   `json:"path"`
+	Version         string   `json:"version"`
+	IsAuthProtected bool     `json:"is_auth_protected"`
+	Auth            []string `json:"auth,omitempty"`
+	Handler         string   `json:"handler"`
+	Middlewares     []string `json:"middlewares"`
+	Owner           string   `json:"owner"`
+}
+
+// Routes lists the registered routes in registration order, with the middlewares each
+// route declares, including those of its groups, and the Handler type it came from.
+func (s *TransportServer) Routes() []RouteInfo {
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+
+	infos := make([]RouteInfo, 0, len(s.routes))
+	for _, entry := range s.routes {
+		middlewares := make([]string, 0, len(entry.route.Middlewares))
+		for _, middleware := range entry.route.Middlewares {
+			middlewares = append(middlewares, funcName(middleware))
+		}
+
+		var authenticators []string
+		if entry.route.Auth != nil {
+			authenticators = entry.route.Auth.Authenticators
+		}
+
+		infos = append(infos, RouteInfo{
+			Name:            entry.route.Name,
+			Method:          entry.method,
+			Path:            entry.path,
+			Version:         entry.version,
+			IsAuthProtected: entry.route.IsAuthProtected,
+			Auth:            authenticators,
+			Handler:         funcName(entry.route.Handler),
+			Middlewares:     middlewares,
+			Owner:           fmt.Sprintf("%T", entry.handler),
+		})
+	}
+
+	return infos
+}
+
+func (s *TransportServer) routesHandler(c *gin.Context) {
+	response.OK(c, s.Routes(), nil)
+}
+
+func funcName(fn any) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
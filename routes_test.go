@@ -0,0 +1,393 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type orderHandler struct {
+	routes []Route
+}
+
+func (h *orderHandler) GetRoutes() []Route {
+	return h.routes
+}
+
+func TestCheckURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		wantErr bool
+	}{
+		{uri: ""},
+		{uri: "/users"},
+		{uri: "/users/:id/orders/"},
+		{uri: "/files/*path"},
+		{uri: "users", wantErr: true},
+		{uri: "/users list", wantErr: true},
+		{uri: "/users/:", wantErr: true},
+		{uri: "/files/*", wantErr: true},
+		{uri: "/users/:id:name", wantErr: true},
+		{uri: "/files/*path/meta", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			err := checkURI(tt.uri)
+			if tt.wantErr && !errors.Is(err, ErrInvalidURI) {
+				t.Errorf("expected ErrInvalidURI, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected URI to be valid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPathsClash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		a, b  string
+		clash bool
+	}{
+		{a: "/users/:id", b: "/users/new"},
+		{a: "/users/:id/orders", b: "/users/:id/:tab"},
+		{a: "/users/:id", b: "/users/:id/"},
+		{a: "/files", b: "/files/*path"},
+		{a: "/users/:id", b: "/users/:name/orders", clash: true},
+		{a: "/files/*path", b: "/files/readme", clash: true},
+		{a: "/files/readme", b: "/files/*path", clash: true},
+		{a: "/files/:name", b: "/files/*path", clash: true},
+		{a: "/files/", b: "/files/*path", clash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := pathsClash(tt.a, tt.b); got != tt.clash {
+				t.Errorf("expected clash %v, got %v", tt.clash, got)
+			}
+
+			// The model must agree with gin's router.
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				engine := gin.New()
+				engine.GET(tt.a, func(*gin.Context) {})
+				engine.GET(tt.b, func(*gin.Context) {})
+				return false
+			}()
+			if panicked != tt.clash {
+				t.Errorf("gin router panicked: %v, model expected clash: %v", panicked, tt.clash)
+			}
+		})
+	}
+}
+
+func TestTransportServerRouteValidation(t *testing.T) {
+	noop := func(c *gin.Context) {}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+	err := server.RegisterHandlers(
+		&mockHandler{
+			routes: []Route{
+				{Uri: "/users/:id", Method: http.MethodGet, Handler: noop},
+				{Uri: "/users", Method: http.MethodPost},
+				{Uri: "users", Method: http.MethodGet, Handler: noop},
+			},
+		},
+		&orderHandler{
+			routes: []Route{
+				{Uri: "/orders", Method: http.MethodGet, Handler: noop},
+				{Uri: "/orders", Method: http.MethodGet, Handler: noop},
+				{Uri: "/users/:name/orders", Method: http.MethodGet, Handler: noop},
+			},
+		},
+	)
+
+	if err == nil {
+		t.Fatal("expected route table errors")
+	}
+	for _, target := range []error{ErrNilHandler, ErrInvalidURI, ErrDuplicateRoute, ErrRouteConflict} {
+		if !errors.Is(err, target) {
+			t.Errorf("expected %v to be reported, got %v", target, err)
+		}
+	}
+	for _, fragment := range []string{"*http.mockHandler: route POST /users", "*http.orderHandler: route GET /users/:name/orders"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected error to contain %q, got %q", fragment, err)
+		}
+	}
+	if routes := server.engine.Routes(); len(routes) != 0 {
+		t.Errorf("expected nothing to be mounted, got %v", routes)
+	}
+}
+
+func TestTransportServerRouteValidationAcrossCalls(t *testing.T) {
+	noop := func(c *gin.Context) {}
+	users := &mockHandler{
+		routes: []Route{
+			{Uri: "/users/:id", Method: http.MethodGet, Handler: noop},
+		},
+	}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(users); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.RegisterHandlers(&mockHandler{routes: users.routes}); !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected ErrDuplicateRoute, got %v", err)
+	}
+
+	err := server.RegisterHandlers(&orderHandler{
+		routes: []Route{
+			{Uri: "/users/:id", Method: http.MethodHead, Handler: noop},
+		},
+	})
+	if !errors.Is(err, ErrDuplicateRoute) {
+		t.Errorf("expected HEAD to clash with the HEAD twin of the GET route, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "automatic HEAD of the GET route of *http.mockHandler") {
+		t.Errorf("expected the error to name the automatic HEAD route, got %v", err)
+	}
+
+	if err := server.RegisterVersion("v2", users); err != nil {
+		t.Errorf("expected the same routes to register under another version, got %v", err)
+	}
+}
+
+type userHandler struct {
+	middlewares []gin.HandlerFunc
+	routes      []Route
+}
+
+func (h *userHandler) Prefix() string {
+	return "/users"
+}
+
+func (h *userHandler) Middlewares() []gin.HandlerFunc {
+	return h.middlewares
+}
+
+func (h *userHandler) GetRoutes() []Route {
+	return h.routes
+}
+
+func TestTransportServerGroupedHandler(t *testing.T) {
+	trace := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Header("X-Trace", c.Writer.Header().Get("X-Trace")+name+";")
+			c.Next()
+		}
+	}
+	respond := func(c *gin.Context) {
+		c.String(http.StatusOK, c.FullPath())
+	}
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithAuthMiddleware(func(c *gin.Context) {
+			if c.GetHeader("Authorization") == "" {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+			c.Next()
+		}),
+	)
+	err := server.RegisterHandlers(
+		&userHandler{
+			middlewares: []gin.HandlerFunc{trace("users")},
+			routes: []Route{
+				{Uri: "", Method: http.MethodGet, Handler: respond},
+				{
+					Uri:             "/:id",
+					IsAuthProtected: true,
+					Middlewares:     []gin.HandlerFunc{trace("user")},
+					Routes: []Route{
+						{Uri: "", Method: http.MethodGet, Handler: respond},
+						{Uri: "/orders", Method: http.MethodGet, Handler: respond, Middlewares: []gin.HandlerFunc{trace("orders")}},
+					},
+				},
+			},
+		},
+		pingHandler(),
+	)
+	if err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		authorized     bool
+		expectedStatus int
+		expectedBody   string
+		expectedTrace  string
+	}{
+		{
+			name:           "handler prefix and middlewares",
+			path:           "/api/v1/users",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/api/v1/users",
+			expectedTrace:  "users;",
+		},
+		{
+			name:           "nested group inherits auth",
+			path:           "/api/v1/users/42",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "nested group middlewares run outside in",
+			path:           "/api/v1/users/42/orders",
+			authorized:     true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/api/v1/users/:id/orders",
+			expectedTrace:  "users;user;orders;",
+		},
+		{
+			name:           "plain handler",
+			path:           "/api/v1/ping",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "pong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorized {
+				req.Header.Set("Authorization", "token")
+			}
+			server.engine.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+			if got := w.Header().Get("X-Trace"); got != tt.expectedTrace {
+				t.Errorf("expected trace %q, got %q", tt.expectedTrace, got)
+			}
+		})
+	}
+}
+
+func TestTransportServerInvalidRouteGroup(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:     "/users",
+				Method:  http.MethodGet,
+				Handler: func(c *gin.Context) {},
+				Routes: []Route{
+					{Uri: "/:id", Method: http.MethodGet, Handler: func(c *gin.Context) {}},
+				},
+			},
+		},
+	})
+
+	if !errors.Is(err, ErrInvalidGroup) {
+		t.Errorf("expected ErrInvalidGroup, got %v", err)
+	}
+}
+
+func auditMiddleware(c *gin.Context) {
+	c.Next()
+}
+
+func listUsers(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+func TestTransportServerRoutes(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	err := server.RegisterHandlers(&userHandler{
+		middlewares: []gin.HandlerFunc{auditMiddleware},
+		routes: []Route{
+			{Uri: "", Method: http.MethodGet, Handler: listUsers, IsAuthProtected: true},
+			{Uri: "/:id", Method: http.MethodDelete, Handler: listUsers},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	routes := server.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expected GET, DELETE and the HEAD twin, got %+v", routes)
+	}
+
+	get := routes[0]
+	if get.Method != http.MethodGet || get.Path != "/api/v1/users" || get.Version != "v1" || !get.IsAuthProtected {
+		t.Errorf("unexpected route info %+v", get)
+	}
+	if !strings.HasSuffix(get.Handler, ".listUsers") {
+		t.Errorf("expected handler name to end with .listUsers, got %q", get.Handler)
+	}
+	if len(get.Middlewares) != 1 || !strings.HasSuffix(get.Middlewares[0], ".auditMiddleware") {
+		t.Errorf("expected the group middleware to be listed, got %v", get.Middlewares)
+	}
+	if get.Owner != "*http.userHandler" {
+		t.Errorf("expected owner *http.userHandler, got %q", get.Owner)
+	}
+
+	if routes[1].Method != http.MethodDelete || routes[1].Path != "/api/v1/users/:id" || routes[1].IsAuthProtected {
+		t.Errorf("unexpected route info %+v", routes[1])
+	}
+	if routes[2].Method != http.MethodHead || routes[2].Path != "/api/v1/users" {
+		t.Errorf("unexpected route info %+v", routes[2])
+	}
+}
+
+func TestTransportServerRoutesEndpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		expectedStatus int
+	}{
+		{name: "dev mode", mode: MODE_DEV, expectedStatus: http.StatusOK},
+		{name: "prod mode", mode: MODE_PROD, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewTransportServer(WithMode(tt.mode), WithRoutesEndpoint("/debug/routes"))
+			defer gin.SetMode(gin.TestMode)
+
+			if err := server.RegisterHandlers(pingHandler()); err != nil {
+				t.Fatalf("expected routes to register, got %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/debug/routes", nil)
+			server.engine.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data []RouteInfo `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if len(body.Data) != 2 || body.Data[0].Path != "/api/v1/ping" {
+				t.Errorf("unexpected routes %+v", body.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+type Hook func(ctx context.Context) error
+
+// OnStart registers hooks that Run calls in order before the server starts listening.
+func (s *TransportServer) OnStart(hooks ...Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.startHooks = append(s.startHooks, hooks...)
+}
+
+// OnShutdown registers hooks that Run calls in order once in-flight requests are drained.
+func (s *TransportServer) OnShutdown(hooks ...Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdownHooks = append(s.shutdownHooks, hooks...)
+}
+
+// Run serves until ctx is cancelled or SIGINT/SIGTERM arrives, then drains in-flight requests
+// within the shutdown timeout and runs the shutdown hooks. SIGHUP triggers Upgrade and, once the
+// new process is ready, the same shutdown. All errors are joined together; a regular shutdown returns nil.
+func (s *TransportServer) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	s.mu.RLock()
+	startHooks := s.startHooks
+	s.mu.RUnlock()
+
+	for _, hook := range startHooks {
+		if err := hook(ctx); err != nil {
+			return errors.Join(err, s.runShutdownHooks())
+		}
+	}
+
+	if err := s.Listen(); err != nil {
+		return errors.Join(err, s.runShutdownHooks())
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve()
+	}()
+
+	errs := s.waitForShutdown(ctx, hup, serveErr)
+	errs = append(errs, s.runShutdownHooks())
+
+	return errors.Join(errs...)
+}
+
+// waitForShutdown blocks until the server fails, ctx is done or a SIGHUP-triggered
+// upgrade has handed the listener over to a new process.
+func (s *TransportServer) waitForShutdown(ctx context.Context, hup <-chan os.Signal, serveErr <-chan error) []error {
+	for {
+		select {
+		case err := <-serveErr:
+			return []error{err}
+		case <-hup:
+			upgradeCtx, cancel := context.WithTimeout(context.Background(), s.cfg.shutdownTimeout)
+			err := s.handOver(upgradeCtx)
+			if err != nil {
+				cancel()
+				s.logf("upgrade failed, keep serving: %v", err)
+				continue
+			}
+
+			err = s.drainHandedOver(upgradeCtx)
+			cancel()
+
+			return filterServerClosed(err, <-serveErr)
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.shutdownTimeout)
+			err := s.Stop(shutdownCtx)
+			cancel()
+
+			return filterServerClosed(err, <-serveErr)
+		}
+	}
+}
+
+func filterServerClosed(stopErr, serveErr error) []error {
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		return []error{stopErr}
+	}
+
+	return []error{stopErr, serveErr}
+}
+
+func (s *TransportServer) runShutdownHooks() error {
+	s.mu.RLock()
+	hooks := s.shutdownHooks
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.shutdownTimeout)
+	defer cancel()
+
+	errs := make([]error, 0, len(hooks))
+	for _, hook := range hooks {
+		errs = append(errs, hook(ctx))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransportServerRunHooks(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string, err error) Hook {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return err
+		}
+	}
+
+	errFirst := errors.New("close db pool")
+	errSecond := errors.New("flush metrics")
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	server.OnStart(record("start-1", nil), record("start-2", nil))
+	server.OnShutdown(record("shutdown-1", errFirst), record("shutdown-2", errSecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(ctx)
+	}()
+
+	<-server.Ready()
+	cancel()
+
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+			t.Errorf("expected both shutdown hook errors, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"start-1", "start-2", "shutdown-1", "shutdown-2"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected call %d to be %q, got %q", i, expected[i], calls[i])
+		}
+	}
+}
+
+func TestTransportServerRunStartHookFailure(t *testing.T) {
+	errStart := errors.New("migrations failed")
+	shutdownCalled := false
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+	server.OnStart(func(ctx context.Context) error {
+		return errStart
+	})
+	server.OnShutdown(func(ctx context.Context) error {
+		shutdownCalled = true
+		return nil
+	})
+
+	if err := server.Run(context.Background()); !errors.Is(err, errStart) {
+		t.Errorf("expected start hook error, got %v", err)
+	}
+	if !shutdownCalled {
+		t.Error("expected shutdown hooks to run after failed start")
+	}
+}
+
+func TestTransportServerRunDrainsInFlightRequests(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithShutdownTimeout(5*time.Second),
+	)
+
+	inFlight := make(chan struct{})
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/slow",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					close(inFlight)
+					time.Sleep(200 * time.Millisecond)
+					c.String(http.StatusOK, "done")
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(ctx)
+	}()
+
+	<-server.Ready()
+	addr := server.Addr().String()
+
+	respErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/api/v1/slow")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		respErr <- err
+	}()
+
+	<-inFlight
+	cancel()
+
+	if err := <-respErr; err != nil {
+		t.Errorf("expected in-flight request to complete, got %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("expected clean shutdown, got %v", err)
+	}
+}
+
+func TestTransportServerRunStopsOnSignal(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(context.Background())
+	}()
+
+	<-server.Ready()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("failed to find own process: %v", err)
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after SIGTERM")
+	}
+}
+
+func TestTransportServerRunListenFailure(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithUnixSocket("/nonexistent-dir/api.sock", 0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Run(context.Background()); err == nil {
+		t.Error("expected listen error")
+	}
+}
+
+func TestTransportServerStopBeforeServe(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Listen(); err != nil {
+		t.Fatalf("expected listen to succeed, got %v", err)
+	}
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("expected stop to succeed, got %v", err)
+	}
+	if err := server.Serve(); !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected http.ErrServerClosed, got %v", err)
+	}
+}
+
+func TestTransportServerRunCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Stop races Serve here; either order must end in a clean shutdown.
+	for i := 0; i < 20; i++ {
+		server := NewTransportServer(
+			WithMode(MODE_TEST),
+			WithHost("127.0.0.1"),
+			WithPort(0),
+		)
+		if err := server.RegisterHandlers(); err != nil {
+			t.Fatalf("expected routes to register, got %v", err)
+		}
+
+		if err := server.Run(ctx); err != nil {
+			t.Fatalf("expected a cancelled Run to return nil, got %v", err)
+		}
+	}
+}
+
+func TestTransportServerRestart(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	for range 2 {
+		if err := server.Listen(); err != nil {
+			t.Fatalf("expected listen to succeed, got %v", err)
+		}
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- server.Serve()
+		}()
+
+		expectPong(t, http.DefaultClient, "http://"+server.Addr().String()+"/api/v1/ping")
+		stopServer(t, server, errChan)
+	}
+}
@@ -2,19 +2,60 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"path"
 	"sync"
 	"time"
 
+	"github.com/elfingit/gin-utils/middleware/cors"
+	"github.com/elfingit/gin-utils/middleware/response"
 	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go/http3"
 )
 
+const (
+	defaultBasePath   = "/api"
+	defaultAPIVersion = "v1"
+)
+
+var ErrNotListening = errors.New("server is not listening, call Listen first")
+
 type TransportServer struct {
 	cfg    *cfg
+	err    error
 	engine *gin.Engine
 	server *http.Server
 	mu     sync.RWMutex
+
+	http3Server *http3.Server
+	packetConn  net.PacketConn
+
+	startHooks    []Hook
+	shutdownHooks []Hook
+
+	versions map[string]struct{}
+
+	routesMu   sync.Mutex
+	frozen     bool
+	groups     map[string]*versionGroups
+	registered []registeredHandler
+	routes     []routeEntry
+	names      map[string]string
+	gate       routeGate
+	cors       routeCORS
+	modules    map[string]struct{}
+
+	listener        net.Listener
+	servedListener  net.Listener // the listener Serve runs on
+	stoppedListener net.Listener // closed by Stop before Serve ran on it
+	handoffListener *handoffListener
+	inherited       bool
+	ready           chan struct{}
+	readyOnce       sync.Once
 }
 
 func NewTransportServer(opts ...Option) *TransportServer {
@@ -28,7 +69,10 @@ func NewTransportServer(opts ...Option) *TransportServer {
 		permissionMiddleware: func(c *gin.Context) {
 			c.Next()
 		},
-		corsMiddleware: corsMiddleware(),
+		corsPolicy:        cors.DefaultPolicy(),
+		basePath:          defaultBasePath,
+		shutdownTimeout:   defaultShutdownTimeout,
+		readHeaderTimeout: 3 * time.Second,
 	}
 
 	for _, opt := range opts {
@@ -45,105 +89,386 @@ func NewTransportServer(opts ...Option) *TransportServer {
 	}
 
 	engine := gin.New()
-	engine.Use(c.corsMiddleware)
+	// gin sets the Allow header from the methods registered for the path.
+	engine.HandleMethodNotAllowed = true
+	engine.NoMethod(func(c *gin.Context) {
+		response.Abort(c, http.StatusMethodNotAllowed, "Method not allowed")
+	})
 
-	return &TransportServer{
+	s := &TransportServer{
 		cfg:    c,
+		err:    c.validate(),
 		engine: engine,
+		ready:  make(chan struct{}),
+		names:  make(map[string]string),
 	}
+	engine.Use(s.corsMiddleware, s.gateMiddleware)
+	engine.NoRoute(s.noRoute)
+	if c.routesEndpoint != "" && c.mode == MODE_DEV {
+		engine.GET(c.routesEndpoint, s.routesHandler)
+	}
+
+	return s
+}
+
+// Err reports the configuration problems found by NewTransportServer. Listen and
+// StartWithListener refuse to start while it is non-nil.
+func (s *TransportServer) Err() error {
+	return s.err
 }
 
 func (s *TransportServer) GetEngine() *gin.Engine {
 	return s.engine
 }
 
-func (s *TransportServer) RegisterHandlers(handlers ...Handler) {
-	s.engine.NoRoute(func(c *gin.Context) {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Not found"})
-	})
+// RegisterHandlers mounts handlers under the default API version, e.g. /api/v1.
+func (s *TransportServer) RegisterHandlers(handlers ...Handler) error {
+	return s.RegisterVersion(defaultAPIVersion, handlers...)
+}
 
-	apiGroup := s.engine.Group("/api/v1")
-	authProtectedGroup := apiGroup.Group("/")
-	authProtectedGroup.Use(s.cfg.authMiddleware)
-	if s.cfg.permissionMiddleware != nil {
-		authProtectedGroup.Use(s.cfg.permissionMiddleware)
+// RegisterVersion mounts handlers under the base path and version, e.g. /api/v2. A route
+// that lists Versions is mounted under each of those instead. Auth protected routes use the
+// middlewares configured for the version, falling back to the server wide ones. GET routes
+// also answer HEAD unless a HEAD route is registered for the same path in the same call;
+// once mounted, the automatic HEAD route cannot be replaced by a later registration.
+//
+// The routes are validated first: unknown methods, missing handlers, malformed URIs,
+// duplicates and wildcard clashes are all reported together and nothing is mounted.
+// Registration is additive and registering a handler again for the same version is a no-op;
+// handlers of a type that cannot be compared count as the same when their routes are.
+// After Freeze it returns ErrFrozen.
+func (s *TransportServer) RegisterVersion(version string, handlers ...Handler) error {
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+
+	if s.frozen {
+		return ErrFrozen
+	}
+
+	handlers = s.unregisteredHandlers(version, handlers)
+
+	entries, err := s.routeTable(version, handlers)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mount(entries); err != nil {
+		return err
 	}
 
 	for _, handler := range handlers {
-		for _, route := range handler.GetRoutes() {
-
-			handlersChain := append([]gin.HandlerFunc{}, route.Middlewares...)
-			handlersChain = append(handlersChain, route.Handler)
-
-			switch route.Method {
-			case http.MethodGet:
-				if route.IsAuthProtected {
-					authProtectedGroup.GET(route.Uri, handlersChain...)
-				} else {
-					apiGroup.GET(route.Uri, handlersChain...)
-				}
-			case http.MethodPost:
-				if route.IsAuthProtected {
-					authProtectedGroup.POST(route.Uri, handlersChain...)
-				} else {
-					apiGroup.POST(route.Uri, handlersChain...)
-				}
-			case http.MethodPut:
-				if route.IsAuthProtected {
-					authProtectedGroup.PUT(route.Uri, handlersChain...)
-				} else {
-					apiGroup.PUT(route.Uri, handlersChain...)
-				}
-			case http.MethodDelete:
-				if route.IsAuthProtected {
-					authProtectedGroup.DELETE(route.Uri, handlersChain...)
-				} else {
-					apiGroup.DELETE(route.Uri, handlersChain...)
-				}
+		s.registered = append(s.registered, registeredHandler{version: version, handler: handler})
+	}
+	s.routes = append(s.routes, entries...)
+	s.gate.nameRoutes(entries)
+	s.cors.addRoutes(entries)
+	for _, entry := range entries {
+		if _, ok := s.names[entry.route.Name]; entry.route.Name != "" && !ok {
+			s.names[entry.route.Name] = entry.path
+		}
+	}
+
+	return nil
+}
+
+func (s *TransportServer) mount(entries []routeEntry) (err error) {
+	// routeTable models gin's router; should they ever disagree, report instead of crashing.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mount routes: %v", r)
+		}
+	}()
+
+	for _, entry := range entries {
+		groups := s.versionGroups(entry.version)
+
+		group := groups.api
+		var handlersChain []gin.HandlerFunc
+
+		switch {
+		case entry.route.Auth != nil:
+			handlersChain = append(handlersChain, s.authenticate(*entry.route.Auth))
+			if _, permission := s.cfg.versionMiddlewares(entry.version); entry.route.IsAuthProtected && permission != nil {
+				handlersChain = append(handlersChain, permission)
 			}
+		case entry.route.IsAuthProtected:
+			group = groups.authProtected
 		}
+
+		handlersChain = append(handlersChain, entry.route.Middlewares...)
+		handlersChain = append(handlersChain, entry.route.Handler)
+
+		group.Handle(entry.method, entry.route.Uri, handlersChain...)
 	}
+
+	return nil
 }
 
-func (s *TransportServer) Start() error {
-	addr := fmt.Sprintf("%s:%d", s.cfg.host, s.cfg.port)
+type versionGroups struct {
+	api           *gin.RouterGroup
+	authProtected *gin.RouterGroup
+}
+
+// versionGroups returns the router groups of version, creating them on first use.
+func (s *TransportServer) versionGroups(version string) *versionGroups {
+	if groups, ok := s.groups[version]; ok {
+		return groups
+	}
 
 	s.mu.Lock()
-	s.server = &http.Server{
-		Addr:              addr,
-		Handler:           s.engine,
-		ReadHeaderTimeout: 3 * time.Second,
+	if s.versions == nil {
+		s.versions = make(map[string]struct{})
 	}
-	srv := s.server
+	s.versions[version] = struct{}{}
 	s.mu.Unlock()
 
-	return srv.ListenAndServe()
+	authMiddleware, permissionMiddleware := s.cfg.versionMiddlewares(version)
+
+	apiGroup := s.engine.Group(path.Join("/", s.cfg.basePath, version), setAPIVersion(version))
+	authProtectedGroup := apiGroup.Group("/")
+	authProtectedGroup.Use(authMiddleware)
+	if permissionMiddleware != nil {
+		authProtectedGroup.Use(permissionMiddleware)
+	}
+
+	if s.groups == nil {
+		s.groups = make(map[string]*versionGroups)
+	}
+	s.groups[version] = &versionGroups{api: apiGroup, authProtected: authProtectedGroup}
+
+	return s.groups[version]
 }
 
-func (s *TransportServer) Stop(ctx context.Context) error {
+func (s *TransportServer) noRoute(c *gin.Context) {
+	if s.negotiateVersion(c) {
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"message": "Not found"})
+}
+
+// Start is Listen followed by Serve. It blocks until the server is stopped.
+func (s *TransportServer) Start() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+
+	return s.Serve()
+}
+
+// StartWithListener serves on a listener opened by the caller, ignoring host, port and other listener options.
+func (s *TransportServer) StartWithListener(l net.Listener) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.setListener(l)
+
+	return s.Serve()
+}
+
+// Listen binds the configured socket without serving it yet, so Addr is known
+// and connections are queued until Serve is called.
+func (s *TransportServer) Listen() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	s.setListener(l)
+
+	return nil
+}
+
+// Serve accepts connections on the socket bound by Listen. It blocks until the server is stopped.
+func (s *TransportServer) Serve() error {
 	s.mu.RLock()
-	srv := s.server
+	l := s.listener
 	s.mu.RUnlock()
 
-	if srv == nil {
+	if l == nil {
+		return ErrNotListening
+	}
+
+	return s.serve(l)
+}
+
+// Addr returns the address the server is bound to, or nil before Listen.
+func (s *TransportServer) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.listener == nil {
 		return nil
 	}
 
-	return srv.Shutdown(ctx)
+	return s.listener.Addr()
+}
+
+// Ready returns a channel that is closed once the server socket accepts connections.
+func (s *TransportServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *TransportServer) setListener(l net.Listener) {
+	s.Freeze()
+
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+
+	if s.inherited {
+		if err := notifyUpgradeReady(); err != nil {
+			s.logf("notify parent process about readiness: %v", err)
+		}
+	}
+}
+
+func (s *TransportServer) logf(format string, args ...any) {
+	if s.cfg.errorLog != nil {
+		s.cfg.errorLog.Printf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
 }
 
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,PATCH,HEAD,OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin,Content-Type,Accept,Authorization,X-Requested-With")
+func (s *TransportServer) serve(l net.Listener) error {
+	tlsConfig, err := s.cfg.buildTLSConfig()
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
 
-		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	var handler http.Handler = s.engine
+	var h3 *http3.Server
+	var packetConn net.PacketConn
+
+	if s.cfg.h2c {
+		handler, err = h2cHandler(handler, tlsConfig)
+		if err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+
+	if s.cfg.http3 {
+		h3, packetConn, err = newHTTP3Server(l.Addr(), s.engine, tlsConfig)
+		if err != nil {
+			_ = l.Close()
+			return err
 		}
+		handler = altSvcHandler(h3, handler)
+	}
+
+	hl := newHandoffListener(l)
 
-		c.Next()
+	s.mu.Lock()
+	// Stop before Serve closes the listener only; report it as a normal stop.
+	if s.stoppedListener == l {
+		s.stoppedListener = nil
+		s.mu.Unlock()
+		_ = l.Close()
+		if h3 != nil {
+			_ = packetConn.Close()
+		}
+		return http.ErrServerClosed
 	}
+	s.servedListener = l
+	s.handoffListener = hl
+	s.server = &http.Server{
+		Addr:              l.Addr().String(),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       s.cfg.readTimeout,
+		ReadHeaderTimeout: s.cfg.readHeaderTimeout,
+		WriteTimeout:      s.cfg.writeTimeout,
+		IdleTimeout:       s.cfg.idleTimeout,
+		MaxHeaderBytes:    s.cfg.maxHeaderBytes,
+		BaseContext:       s.cfg.baseContext,
+		ConnContext:       s.cfg.connContext,
+		ErrorLog:          s.cfg.errorLog,
+		ConnState:         hl.trackConnState,
+	}
+	s.http3Server = h3
+	s.packetConn = packetConn
+	srv := s.server
+	s.mu.Unlock()
+
+	if s.cfg.certWatcher != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.cfg.certWatcher.Watch(ctx)
+	}
+
+	var h3Done chan error
+	if h3 != nil {
+		h3Done = make(chan error, 1)
+		go func() {
+			err := h3.Serve(packetConn)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				_ = srv.Close()
+			}
+			h3Done <- err
+		}()
+	}
+
+	if tlsConfig != nil {
+		err = srv.ServeTLS(hl, "", "")
+	} else {
+		err = srv.Serve(hl)
+	}
+
+	if h3Done != nil {
+		if !errors.Is(err, http.ErrServerClosed) {
+			_ = h3.Close()
+			_ = packetConn.Close()
+		}
+		if h3Err := <-h3Done; h3Err != nil && !errors.Is(h3Err, http.ErrServerClosed) {
+			return h3Err
+		}
+	}
+
+	return err
+}
+
+// Stop shuts the server down gracefully. Called between Listen and Serve, it closes the
+// listener and makes Serve return http.ErrServerClosed. A stopped server can be started
+// again.
+func (s *TransportServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.server
+	h3 := s.http3Server
+	packetConn := s.packetConn
+	l := s.listener
+	served := l != nil && l == s.servedListener
+	if l != nil && !served {
+		s.stoppedListener = l
+	}
+	s.mu.Unlock()
+
+	if l != nil && !served {
+		_ = l.Close()
+		return nil
+	}
+	if srv == nil {
+		return nil
+	}
+
+	err := srv.Shutdown(ctx)
+
+	if h3 != nil {
+		err = errors.Join(err, h3.Shutdown(ctx))
+		_ = packetConn.Close()
+	}
+
+	return err
 }
@@ -2,11 +2,19 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/elfingit/gin-utils/middleware/response"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,6 +26,30 @@ func (m *mockHandler) GetRoutes() []Route {
 	return m.routes
 }
 
+// newTestServer creates a test mode server with handler registered under v1.
+func newTestServer(t *testing.T, handler Handler, opts ...Option) *TransportServer {
+	t.Helper()
+
+	server := NewTransportServer(append([]Option{WithMode(MODE_TEST)}, opts...)...)
+	if err := server.RegisterHandlers(handler); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	return server
+}
+
+// serveRequest serves a request with headers through the server's engine.
+func serveRequest(server *TransportServer, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	server.engine.ServeHTTP(w, req)
+
+	return w
+}
+
 func TestNewTransportServer(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -167,6 +199,88 @@ func TestTransportServerRegisterHandlers(t *testing.T) {
 			expectedStatus:  http.StatusNoContent,
 			isAuthProtected: false,
 		},
+		{
+			name: "register PATCH route",
+			routes: []Route{
+				{
+					Uri:    "/patch",
+					Method: http.MethodPatch,
+					Handler: func(c *gin.Context) {
+						c.JSON(http.StatusOK, gin.H{"message": "patched"})
+					},
+				},
+			},
+			expectedMethod: http.MethodPatch,
+			expectedPath:   "/api/v1/patch",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "register lower case method",
+			routes: []Route{
+				{
+					Uri:    "/patch",
+					Method: "patch",
+					Handler: func(c *gin.Context) {
+						c.JSON(http.StatusOK, gin.H{"message": "patched"})
+					},
+				},
+			},
+			expectedMethod: http.MethodPatch,
+			expectedPath:   "/api/v1/patch",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "register ANY route",
+			routes: []Route{
+				{
+					Uri:    "/any",
+					Method: MethodAny,
+					Handler: func(c *gin.Context) {
+						c.JSON(http.StatusAccepted, gin.H{"message": "any"})
+					},
+				},
+			},
+			expectedMethod: http.MethodPut,
+			expectedPath:   "/api/v1/any",
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "GET route answers HEAD",
+			routes: []Route{
+				{
+					Uri:    "/test",
+					Method: http.MethodGet,
+					Handler: func(c *gin.Context) {
+						c.JSON(http.StatusOK, gin.H{"message": "success"})
+					},
+				},
+			},
+			expectedMethod: http.MethodHead,
+			expectedPath:   "/api/v1/test",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "explicit HEAD route takes precedence",
+			routes: []Route{
+				{
+					Uri:    "/test",
+					Method: http.MethodGet,
+					Handler: func(c *gin.Context) {
+						c.JSON(http.StatusOK, gin.H{"message": "success"})
+					},
+				},
+				{
+					Uri:    "/test",
+					Method: http.MethodHead,
+					Handler: func(c *gin.Context) {
+						c.Status(http.StatusNoContent)
+					},
+				},
+			},
+			expectedMethod: http.MethodHead,
+			expectedPath:   "/api/v1/test",
+			expectedStatus: http.StatusNoContent,
+		},
 	}
 
 	for _, tt := range tests {
@@ -174,7 +288,9 @@ func TestTransportServerRegisterHandlers(t *testing.T) {
 			server := NewTransportServer(WithMode(MODE_TEST))
 			handler := &mockHandler{routes: tt.routes}
 
-			server.RegisterHandlers(handler)
+			if err := server.RegisterHandlers(handler); err != nil {
+				t.Fatalf("expected routes to register, got %v", err)
+			}
 
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.expectedMethod, tt.expectedPath, nil)
@@ -217,7 +333,9 @@ func TestTransportServerAuthProtectedRoute(t *testing.T) {
 		},
 	}
 
-	server.RegisterHandlers(handler)
+	if err := server.RegisterHandlers(handler); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
 
 	t.Run("unauthorized request", func(t *testing.T) {
 		authCalled = false
@@ -251,9 +369,138 @@ func TestTransportServerAuthProtectedRoute(t *testing.T) {
 	})
 }
 
+func TestTransportServerRegisterOptionsRoute(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithCorsMiddleware(func(c *gin.Context) {
+			c.Next()
+		}),
+	)
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/upload",
+				Method: http.MethodOptions,
+				Handler: func(c *gin.Context) {
+					c.Header("Allow", "OPTIONS, PUT")
+					c.Status(http.StatusOK)
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodOptions, "/api/v1/upload", nil)
+	server.engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Allow") != "OPTIONS, PUT" {
+		t.Errorf("expected OPTIONS handler to answer, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestTransportServerRegisterUnknownMethod(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:     "/valid",
+				Method:  http.MethodGet,
+				Handler: func(c *gin.Context) {},
+			},
+			{
+				Uri:     "/items",
+				Method:  "FETCH",
+				Handler: func(c *gin.Context) {},
+			},
+		},
+	})
+
+	if !errors.Is(err, ErrUnknownMethod) {
+		t.Fatalf("expected ErrUnknownMethod, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "FETCH") || !strings.Contains(err.Error(), "/items") {
+		t.Errorf("expected error to name the route, got %q", err)
+	}
+	if routes := server.engine.Routes(); len(routes) != 0 {
+		t.Errorf("expected nothing to be mounted, got %v", routes)
+	}
+}
+
+func TestTransportServerRegisterVersion(t *testing.T) {
+	rejectAll := func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+	versionHandler := func(version string) *mockHandler {
+		return &mockHandler{
+			routes: []Route{
+				{
+					Uri:    "/users",
+					Method: http.MethodGet,
+					Handler: func(c *gin.Context) {
+						c.String(http.StatusOK, version)
+					},
+				},
+				{
+					Uri:             "/me",
+					Method:          http.MethodGet,
+					IsAuthProtected: true,
+					Handler: func(c *gin.Context) {
+						c.String(http.StatusOK, version)
+					},
+				},
+			},
+		}
+	}
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithBasePath("/public"),
+		WithVersionAuthMiddleware("v2", rejectAll),
+	)
+	if err := server.RegisterHandlers(versionHandler("v1")); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+	if err := server.RegisterVersion("v2", versionHandler("v2")); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "default version", path: "/public/v1/users", expectedStatus: http.StatusOK, expectedBody: "v1"},
+		{name: "second version", path: "/public/v2/users", expectedStatus: http.StatusOK, expectedBody: "v2"},
+		{name: "server wide auth middleware", path: "/public/v1/me", expectedStatus: http.StatusOK, expectedBody: "v1"},
+		{name: "version auth middleware", path: "/public/v2/me", expectedStatus: http.StatusUnauthorized},
+		{name: "old base path", path: "/api/v1/users", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			server.engine.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestTransportServerNotFoundRoute(t *testing.T) {
 	server := NewTransportServer(WithMode(MODE_TEST))
-	server.RegisterHandlers()
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/nonexistent", nil)
@@ -264,6 +511,42 @@ func TestTransportServerNotFoundRoute(t *testing.T) {
 	}
 }
 
+func TestTransportServerMethodNotAllowed(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+	noop := func(c *gin.Context) {}
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{Uri: "/users", Method: http.MethodGet, Handler: noop},
+			{Uri: "/users", Method: http.MethodPost, Handler: noop},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users", nil)
+	server.engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	allowed := strings.Split(w.Header().Get("Allow"), ", ")
+	sort.Strings(allowed)
+	if strings.Join(allowed, ",") != "GET,HEAD,POST" {
+		t.Errorf("unexpected Allow header %q", w.Header().Get("Allow"))
+	}
+
+	var envelope response.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if envelope.Error == nil || envelope.Error.Code != http.StatusMethodNotAllowed || envelope.Error.Message != "Method not allowed" {
+		t.Errorf("expected 405 error envelope, got %s", w.Body.String())
+	}
+}
+
 func TestTransportServerWithMiddlewares(t *testing.T) {
 	middleware1Called := false
 	middleware2Called := false
@@ -293,7 +576,9 @@ func TestTransportServerWithMiddlewares(t *testing.T) {
 		},
 	}
 
-	server.RegisterHandlers(handler)
+	if err := server.RegisterHandlers(handler); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/test", nil)
@@ -316,10 +601,16 @@ func TestTransportServerStartStop(t *testing.T) {
 	server := NewTransportServer(
 		WithMode(MODE_TEST),
 		WithHost("localhost"),
-		WithPort(18080), // Fixed port for testing
+		WithPort(0),
 	)
 
-	server.RegisterHandlers()
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if server.Addr() != nil {
+		t.Errorf("expected no address before start, got %v", server.Addr())
+	}
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
@@ -327,28 +618,31 @@ func TestTransportServerStartStop(t *testing.T) {
 		errChan <- server.Start()
 	}()
 
-	// Wait for server to be ready by attempting connections
-	serverReady := false
-	for i := 0; i < 50; i++ {
-		time.Sleep(20 * time.Millisecond)
-		server.mu.RLock()
-		ready := server.server != nil
-		server.mu.RUnlock()
-		if ready {
-			serverReady = true
-			break
-		}
+	select {
+	case <-server.Ready():
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not start in time")
 	}
 
-	if !serverReady {
-		t.Fatal("server did not start in time")
+	addr, ok := server.Addr().(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("expected TCP address, got %v", server.Addr())
+	}
+	if addr.Port == 0 {
+		t.Error("expected an actual port to be reported")
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/unknown")
+	if err != nil {
+		t.Fatalf("expected server to accept connections, got %v", err)
 	}
+	resp.Body.Close()
 
 	// Stop server
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := server.Stop(ctx)
+	err = server.Stop(ctx)
 	if err != nil {
 		t.Errorf("expected no error on stop, got %v", err)
 	}
@@ -364,6 +658,88 @@ func TestTransportServerStartStop(t *testing.T) {
 	}
 }
 
+func TestTransportServerListenServe(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+	)
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Serve(); !errors.Is(err, ErrNotListening) {
+		t.Errorf("expected ErrNotListening, got %v", err)
+	}
+
+	if err := server.Listen(); err != nil {
+		t.Fatalf("expected no error on listen, got %v", err)
+	}
+
+	select {
+	case <-server.Ready():
+	default:
+		t.Fatal("expected Ready to be closed after Listen")
+	}
+
+	// Connections are queued by the kernel until Serve is called.
+	addr := server.Addr().String()
+	respChan := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/unknown")
+		if err != nil {
+			respChan <- 0
+			return
+		}
+		resp.Body.Close()
+		respChan <- resp.StatusCode
+	}()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve()
+	}()
+
+	if code := <-respChan; code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		t.Errorf("expected no error on stop, got %v", err)
+	}
+	if err := <-errChan; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestTransportServerStopAfterListen(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+	)
+
+	if err := server.Listen(); err != nil {
+		t.Fatalf("expected no error on listen, got %v", err)
+	}
+	addr := server.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		t.Errorf("expected no error on stop, got %v", err)
+	}
+
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("expected listener to be closed by Stop")
+	}
+}
+
 func TestTransportServerStopWithoutStart(t *testing.T) {
 	server := NewTransportServer(
 		WithMode(MODE_TEST),
@@ -371,7 +747,9 @@ func TestTransportServerStopWithoutStart(t *testing.T) {
 		WithPort(18081),
 	)
 
-	server.RegisterHandlers()
+	if err := server.RegisterHandlers(); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
 
 	// Try to stop server that was never started
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -397,24 +775,30 @@ func TestTransportServerGetEngine(t *testing.T) {
 
 func TestTransportServerGlobalCORSMiddleware(t *testing.T) {
 	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(routeHandler("/users")); err != nil {
+		t.Fatalf("expected registration to succeed, got %v", err)
+	}
 
 	t.Run("adds CORS headers to regular request", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, "/unknown", nil)
+		req.Header.Set("Origin", "https://app.example.com")
 		server.engine.ServeHTTP(w, req)
 
 		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
 			t.Errorf("expected Access-Control-Allow-Origin to be '*', got %q", got)
 		}
 
-		if got := w.Header().Get("Access-Control-Allow-Methods"); got == "" {
-			t.Error("expected Access-Control-Allow-Methods to be set")
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("expected no Access-Control-Allow-Credentials with '*', got %q", got)
 		}
 	})
 
 	t.Run("handles preflight OPTIONS globally", func(t *testing.T) {
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodOptions, "/unknown", nil)
+		req, _ := http.NewRequest(http.MethodOptions, "/api/v1/users", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
 		server.engine.ServeHTTP(w, req)
 
 		if w.Code != http.StatusNoContent {
@@ -424,6 +808,10 @@ func TestTransportServerGlobalCORSMiddleware(t *testing.T) {
 		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
 			t.Errorf("expected Access-Control-Allow-Origin to be '*', got %q", got)
 		}
+
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, HEAD" {
+			t.Errorf("expected the methods routed for the path, got %q", got)
+		}
 	})
 }
 
@@ -487,7 +875,9 @@ func TestTransportServerAuthProtectedNonGetRoutes(t *testing.T) {
 				},
 			}
 
-			server.RegisterHandlers(handler)
+			if err := server.RegisterHandlers(handler); err != nil {
+				t.Fatalf("expected routes to register, got %v", err)
+			}
 
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.method, tt.path, nil)
@@ -533,7 +923,9 @@ func TestTransportServerPermissionMiddleware(t *testing.T) {
 		},
 	}
 
-	server.RegisterHandlers(handler)
+	if err := server.RegisterHandlers(handler); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
 
 	t.Run("forbidden request", func(t *testing.T) {
 		permissionCalled = false
@@ -567,3 +959,84 @@ func TestTransportServerPermissionMiddleware(t *testing.T) {
 		}
 	})
 }
+
+func TestTransportServerInvalidConfiguration(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithReadTimeout(time.Second),
+		WithReadHeaderTimeout(time.Minute),
+	)
+
+	if server.Err() == nil {
+		t.Fatal("expected configuration error to be reported")
+	}
+
+	if err := server.Start(); err != server.Err() {
+		t.Errorf("expected Start to return configuration error, got %v", err)
+	}
+
+	if server.Addr() != nil {
+		t.Error("expected server not to bind with invalid configuration")
+	}
+}
+
+func TestTransportServerAppliesServerOptions(t *testing.T) {
+	type ctxKey struct{}
+
+	var logged strings.Builder
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithReadTimeout(10*time.Second),
+		WithWriteTimeout(20*time.Second),
+		WithIdleTimeout(30*time.Second),
+		WithMaxHeaderBytes(4096),
+		WithErrorLog(log.New(&logged, "", 0)),
+		WithConnContext(func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, ctxKey{}, "conn")
+		}),
+	)
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/ctx",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					v, _ := c.Request.Context().Value(ctxKey{}).(string)
+					c.String(http.StatusOK, v)
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	server.mu.RLock()
+	srv := server.server
+	server.mu.RUnlock()
+
+	if srv.ReadTimeout != 10*time.Second || srv.WriteTimeout != 20*time.Second || srv.IdleTimeout != 30*time.Second {
+		t.Errorf("unexpected timeouts: read %s, write %s, idle %s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+	if srv.ReadHeaderTimeout != 3*time.Second {
+		t.Errorf("expected default read header timeout %s, got %s", 3*time.Second, srv.ReadHeaderTimeout)
+	}
+	if srv.MaxHeaderBytes != 4096 {
+		t.Errorf("expected max header bytes %d, got %d", 4096, srv.MaxHeaderBytes)
+	}
+
+	resp, err := http.Get("http://" + addr + "/api/v1/ctx")
+	if err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "conn" {
+		t.Errorf("expected connection context value %q, got %q", "conn", body)
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrNoSystemdListeners = errors.New("no listeners were passed by systemd socket activation")
+
+// listenFdsStart is the first file descriptor passed by systemd, see sd_listen_fds(3).
+var listenFdsStart = 3
+
+// SystemdListeners returns the sockets passed to this process by systemd socket activation.
+// The LISTEN_* variables are unset so child processes don't inherit them.
+func SystemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoSystemdListeners
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, ErrNoSystemdListeners
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFdsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("systemd listener %s: %w", name, err)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func passSystemdListener(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("failed to get listener file: %v", err)
+	}
+
+	defer f.Close()
+
+	// Hand over a descriptor that no *os.File owns, as systemd would.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("failed to dup listener: %v", err)
+	}
+
+	original := listenFdsStart
+	listenFdsStart = fd
+	t.Cleanup(func() { listenFdsStart = original })
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+
+	return l.Addr().String()
+}
+
+func TestSystemdListeners(t *testing.T) {
+	t.Run("not activated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "")
+		t.Setenv("LISTEN_FDS", "")
+
+		if _, err := SystemdListeners(); !errors.Is(err, ErrNoSystemdListeners) {
+			t.Errorf("expected ErrNoSystemdListeners, got %v", err)
+		}
+	})
+
+	t.Run("activated for another process", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		t.Setenv("LISTEN_FDS", "1")
+
+		if _, err := SystemdListeners(); !errors.Is(err, ErrNoSystemdListeners) {
+			t.Errorf("expected ErrNoSystemdListeners, got %v", err)
+		}
+	})
+
+	t.Run("inherits listener", func(t *testing.T) {
+		addr := passSystemdListener(t)
+
+		listeners, err := SystemdListeners()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		defer listeners[0].Close()
+
+		if len(listeners) != 1 {
+			t.Fatalf("expected 1 listener, got %d", len(listeners))
+		}
+		if got := listeners[0].Addr().String(); got != addr {
+			t.Errorf("expected address %s, got %s", addr, got)
+		}
+		if os.Getenv("LISTEN_FDS") != "" || os.Getenv("LISTEN_PID") != "" {
+			t.Error("expected LISTEN_* variables to be unset")
+		}
+	})
+}
+
+func TestTransportServerSystemdListener(t *testing.T) {
+	addr := passSystemdListener(t)
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithSystemdListener(),
+	)
+	if err := server.RegisterHandlers(pingHandler()); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	_, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	expectPong(t, http.DefaultClient, "http://"+addr+"/api/v1/ping")
+}
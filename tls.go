@@ -0,0 +1,90 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrNoCertificate = errors.New("TLS is enabled but no server certificate is configured")
+	// ErrCertWatcherWithCertificates is returned when a CertWatcher is combined with static
+	// certificates: crypto/tls prefers those for clients that send no SNI, which would keep
+	// getting the old certificate after a rotation.
+	ErrCertWatcherWithCertificates = errors.New("a certificate watcher cannot be combined with static certificates")
+)
+
+type PeerIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+	Certificate    *x509.Certificate
+}
+
+func (c *cfg) tlsEnabled() bool {
+	return c.tlsConfig != nil || c.tlsCertFile != "" || c.clientCAs != nil || c.certWatcher != nil
+}
+
+func (c *cfg) buildTLSConfig() (*tls.Config, error) {
+	if !c.tlsEnabled() {
+		return nil, nil
+	}
+
+	var tlsConfig *tls.Config
+	if c.tlsConfig != nil {
+		tlsConfig = c.tlsConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if c.tlsCertFile != "" || c.tlsKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.tlsCertFile, c.tlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS key pair: %w", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if c.certWatcher != nil {
+		tlsConfig.GetCertificate = c.certWatcher.GetCertificate
+	}
+
+	if c.clientCAs != nil {
+		tlsConfig.ClientCAs = c.clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil && tlsConfig.GetConfigForClient == nil {
+		return nil, ErrNoCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// GetPeerIdentity returns the identity of a client whose certificate was verified
+// during the TLS handshake, or nil when the connection carries no verified certificate.
+func GetPeerIdentity(c *gin.Context) *PeerIdentity {
+	if c.Request == nil || c.Request.TLS == nil {
+		return nil
+	}
+
+	chains := c.Request.TLS.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+
+	cert := chains[0][0]
+
+	return &PeerIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}
+}
@@ -0,0 +1,431 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (tc *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(tc.certPEM, tc.keyPEM)
+	if err != nil {
+		t.Fatalf("failed to build key pair: %v", err)
+	}
+
+	return cert
+}
+
+func (tc *testCert) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(tc.cert)
+
+	return pool
+}
+
+func (tc *testCert) writeFiles(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	if err := os.WriteFile(certFile, tc.certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, tc.keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func newTestCA(t *testing.T) *testCert {
+	t.Helper()
+
+	return issueTestCert(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	})
+}
+
+func newTestServerCert(t *testing.T, ca *testCert) *testCert {
+	t.Helper()
+
+	return issueTestCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func newTestClientCert(t *testing.T, ca *testCert, commonName string) *testCert {
+	t.Helper()
+
+	return issueTestCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		DNSNames:    []string{commonName + ".clients.local"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func issueTestCert(t *testing.T, parent *testCert, template *x509.Certificate) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("failed to generate serial: %v", err)
+	}
+
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func startTestServer(t *testing.T, server *TransportServer) (string, chan error) {
+	t.Helper()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start()
+	}()
+
+	select {
+	case <-server.Ready():
+	case err := <-errChan:
+		t.Fatalf("server failed to start: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not start in time")
+	}
+
+	return server.Addr().String(), errChan
+}
+
+func stopServer(t *testing.T, server *TransportServer, errChan chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		t.Errorf("expected no error on stop, got %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("server did not stop in time")
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := newTestServerCert(t, ca)
+	certFile, keyFile := serverCert.writeFiles(t, t.TempDir())
+
+	t.Run("disabled by default", func(t *testing.T) {
+		c := &cfg{}
+
+		tlsConfig, err := c.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if tlsConfig != nil {
+			t.Error("expected nil TLS config when TLS is not configured")
+		}
+	})
+
+	t.Run("loads key pair from files", func(t *testing.T) {
+		c := &cfg{tlsCertFile: certFile, tlsKeyFile: keyFile}
+
+		tlsConfig, err := c.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(tlsConfig.Certificates) != 1 {
+			t.Errorf("expected 1 certificate, got %d", len(tlsConfig.Certificates))
+		}
+		if tlsConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("expected TLS 1.2 minimum version, got %x", tlsConfig.MinVersion)
+		}
+	})
+
+	t.Run("fails on missing key pair", func(t *testing.T) {
+		c := &cfg{tlsCertFile: "missing.pem", tlsKeyFile: "missing.key"}
+
+		if _, err := c.buildTLSConfig(); err == nil {
+			t.Error("expected error for missing key pair")
+		}
+	})
+
+	t.Run("clones custom config", func(t *testing.T) {
+		custom := &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{serverCert.tlsCertificate(t)},
+		}
+		c := &cfg{tlsConfig: custom, clientCAs: ca.pool()}
+
+		tlsConfig, err := c.buildTLSConfig()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if tlsConfig == custom {
+			t.Error("expected custom config to be cloned")
+		}
+		if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Errorf("expected client certificates to be required, got %v", tlsConfig.ClientAuth)
+		}
+		if custom.ClientAuth != tls.NoClientCert {
+			t.Error("expected custom config to stay untouched")
+		}
+	})
+
+	t.Run("fails without certificate", func(t *testing.T) {
+		c := &cfg{clientCAs: ca.pool()}
+
+		if _, err := c.buildTLSConfig(); !errors.Is(err, ErrNoCertificate) {
+			t.Errorf("expected ErrNoCertificate, got %v", err)
+		}
+	})
+}
+
+func TestTransportServerTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := newTestServerCert(t, ca)
+	certFile, keyFile := serverCert.writeFiles(t, t.TempDir())
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithTLS(certFile, keyFile),
+	)
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/secure",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.JSON(http.StatusOK, gin.H{"tls": c.Request.TLS != nil})
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: ca.pool(), MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	resp, err := client.Get("https://" + addr + "/api/v1/secure")
+	if err != nil {
+		t.Fatalf("expected TLS request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.TLS == nil {
+		t.Error("expected response to be served over TLS")
+	}
+}
+
+func TestTransportServerMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := newTestServerCert(t, ca)
+	clientCert := newTestClientCert(t, ca, "billing")
+	otherCA := newTestCA(t)
+	foreignCert := newTestClientCert(t, otherCA, "intruder")
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithPort(0),
+		WithTLSConfig(&tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{serverCert.tlsCertificate(t)},
+		}),
+		WithMutualTLS(ca.pool()),
+	)
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/whoami",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					peer := GetPeerIdentity(c)
+					if peer == nil {
+						c.JSON(http.StatusUnauthorized, gin.H{})
+						return
+					}
+					c.String(http.StatusOK, peer.CommonName)
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      ca.pool(),
+					Certificates: certs,
+					MinVersion:   tls.VersionTLS12,
+				},
+			},
+		}
+	}
+
+	t.Run("verified client certificate", func(t *testing.T) {
+		resp, err := newClient(clientCert.tlsCertificate(t)).Get("https://" + addr + "/api/v1/whoami")
+		if err != nil {
+			t.Fatalf("expected request to succeed, got %v", err)
+		}
+		defer resp.Body.Close()
+
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if got := string(body[:n]); got != "billing" {
+			t.Errorf("expected peer identity %q, got %q", "billing", got)
+		}
+	})
+
+	t.Run("missing client certificate", func(t *testing.T) {
+		resp, err := newClient().Get("https://" + addr + "/api/v1/whoami")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("expected handshake to fail without client certificate")
+		}
+	})
+
+	t.Run("client certificate from unknown CA", func(t *testing.T) {
+		resp, err := newClient(foreignCert.tlsCertificate(t)).Get("https://" + addr + "/api/v1/whoami")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("expected handshake to fail with untrusted client certificate")
+		}
+	})
+}
+
+func TestGetPeerIdentity(t *testing.T) {
+	ca := newTestCA(t)
+	clientCert := newTestClientCert(t, ca, "reports")
+
+	t.Run("plain HTTP request", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if peer := GetPeerIdentity(c); peer != nil {
+			t.Errorf("expected nil identity, got %+v", peer)
+		}
+	})
+
+	t.Run("TLS without verified chains", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert.cert}}
+
+		if peer := GetPeerIdentity(c); peer != nil {
+			t.Errorf("expected nil identity for unverified certificate, got %+v", peer)
+		}
+	})
+
+	t.Run("verified chain", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{clientCert.cert, ca.cert}},
+		}
+
+		peer := GetPeerIdentity(c)
+		if peer == nil {
+			t.Fatal("expected identity to be returned")
+		}
+		if peer.CommonName != "reports" {
+			t.Errorf("expected common name %q, got %q", "reports", peer.CommonName)
+		}
+		if len(peer.DNSNames) != 1 || peer.DNSNames[0] != "reports.clients.local" {
+			t.Errorf("unexpected DNS names %v", peer.DNSNames)
+		}
+		if peer.Certificate != clientCert.cert {
+			t.Error("expected leaf certificate to be exposed")
+		}
+	})
+}
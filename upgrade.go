@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	envUpgradeListenerFD = "GIN_UTILS_UPGRADE_LISTENER_FD"
+	envUpgradeReadyFD    = "GIN_UTILS_UPGRADE_READY_FD"
+)
+
+var (
+	ErrUpgradeHTTP3        = errors.New("upgrade cannot hand over the HTTP/3 listener")
+	ErrUpgradeNotSupported = errors.New("listener cannot be handed over to a new process")
+	ErrUpgradeChildExited  = errors.New("new process exited before signalling readiness")
+)
+
+// Upgrade performs a zero-downtime binary upgrade: it starts the upgrade command (by default
+// the current executable with the same arguments), passes it the listening socket, waits until
+// the new process is listening and then drains this server. ctx bounds both the wait and the drain.
+func (s *TransportServer) Upgrade(ctx context.Context) error {
+	if err := s.handOver(ctx); err != nil {
+		return err
+	}
+
+	return s.drainHandedOver(ctx)
+}
+
+// drainHandedOver stops accepting on the shared socket, so the new process gets every new
+// connection, lets connections already accepted here send their first request and then drains.
+func (s *TransportServer) drainHandedOver(ctx context.Context) error {
+	s.mu.RLock()
+	srv := s.server
+	hl := s.handoffListener
+	s.mu.RUnlock()
+
+	if srv != nil && hl != nil {
+		hl.pause()
+		srv.SetKeepAlivesEnabled(false)
+		hl.waitForNewConns(ctx)
+	}
+
+	return s.Stop(ctx)
+}
+
+// handOver starts the new process with the listening socket and waits until it is ready.
+func (s *TransportServer) handOver(ctx context.Context) error {
+	if s.cfg.http3 {
+		return ErrUpgradeHTTP3
+	}
+
+	s.mu.RLock()
+	l := s.listener
+	s.mu.RUnlock()
+
+	if l == nil {
+		return ErrNotListening
+	}
+
+	listenerFile, err := listenerFile(l)
+	if err != nil {
+		return err
+	}
+	defer listenerFile.Close()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	cmd, err := s.upgradeCommand()
+	if err != nil {
+		_ = readyWriter.Close()
+		return err
+	}
+
+	// ExtraFiles[i] becomes file descriptor 3+i in the new process.
+	cmd.ExtraFiles = []*os.File{listenerFile, readyWriter}
+	cmd.Env = append(os.Environ(), envUpgradeListenerFD+"=3", envUpgradeReadyFD+"=4")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	_ = readyWriter.Close()
+	// Passing the socket to the new process switched it to blocking mode, which would leave our
+	// Accept stuck in a syscall that closing the listener cannot interrupt.
+	restoreNonblock(l)
+	if err != nil {
+		return fmt.Errorf("start new process: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyReader.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = ErrUpgradeChildExited
+		}
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return err
+		}
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("wait for new process: %w", ctx.Err())
+	}
+
+	// The new process owns the socket from now on, closing ours must not unlink it.
+	if unixListener, ok := l.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	return nil
+}
+
+func (s *TransportServer) upgradeCommand() (*exec.Cmd, error) {
+	if s.cfg.upgradePath != "" {
+		return exec.Command(s.cfg.upgradePath, s.cfg.upgradeArgs...), nil // #nosec G204 -- configured by the application
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("resolve executable: %w", err)
+	}
+
+	return exec.Command(path, os.Args[1:]...), nil // #nosec G204 -- re-executes the running binary
+}
+
+func listenerFile(l net.Listener) (*os.File, error) {
+	filer, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, ErrUpgradeNotSupported
+	}
+
+	return filer.File()
+}
+
+// inheritedListener returns the socket handed over by Upgrade in the parent process, if any.
+func inheritedListener() (net.Listener, bool, error) {
+	value, ok := os.LookupEnv(envUpgradeListenerFD)
+	if !ok {
+		return nil, false, nil
+	}
+	_ = os.Unsetenv(envUpgradeListenerFD)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid %s %q", envUpgradeListenerFD, value)
+	}
+
+	f := os.NewFile(uintptr(fd), "upgrade-listener")
+	defer f.Close()
+
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, true, fmt.Errorf("inherit listener: %w", err)
+	}
+
+	return l, true, nil
+}
+
+// notifyUpgradeReady tells the parent process that this process is accepting connections.
+func notifyUpgradeReady() error {
+	value, ok := os.LookupEnv(envUpgradeReadyFD)
+	if !ok {
+		return nil
+	}
+	_ = os.Unsetenv(envUpgradeReadyFD)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", envUpgradeReadyFD, value)
+	}
+
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	defer f.Close()
+
+	_, err = f.Write([]byte{1})
+
+	return err
+}
+
+// handoffListener lets Upgrade stop accepting connections without shutting the server down
+// and tracks accepted connections whose first request has not been read yet.
+type handoffListener struct {
+	net.Listener
+
+	paused    chan struct{}
+	closed    chan struct{}
+	pauseOnce sync.Once
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	newConns map[net.Conn]struct{}
+}
+
+func newHandoffListener(l net.Listener) *handoffListener {
+	return &handoffListener{
+		Listener: l,
+		paused:   make(chan struct{}),
+		closed:   make(chan struct{}),
+		newConns: make(map[net.Conn]struct{}),
+	}
+}
+
+func (l *handoffListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.paused:
+			// Keep http.Server.Serve waiting until Shutdown, so it returns ErrServerClosed.
+			<-l.closed
+			return nil, net.ErrClosed
+		default:
+		}
+	}
+
+	return conn, err
+}
+
+func (l *handoffListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+
+	select {
+	case <-l.paused:
+		return nil
+	default:
+		return l.Listener.Close()
+	}
+}
+
+func (l *handoffListener) pause() {
+	l.pauseOnce.Do(func() {
+		close(l.paused)
+		_ = l.Listener.Close()
+	})
+}
+
+func (l *handoffListener) trackConnState(conn net.Conn, state http.ConnState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if state == http.StateNew {
+		l.newConns[conn] = struct{}{}
+	} else {
+		delete(l.newConns, conn)
+	}
+}
+
+func (l *handoffListener) waitForNewConns(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		l.mu.Lock()
+		pending := len(l.newConns)
+		l.mu.Unlock()
+
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package http
+
+import "net"
+
+func restoreNonblock(net.Listener) {}
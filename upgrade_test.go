@@ -0,0 +1,223 @@
+//go:build linux
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const envUpgradeTestChild = "GIN_UTILS_TEST_UPGRADE_CHILD"
+
+func whoamiHandler(role string) *mockHandler {
+	return &mockHandler{
+		routes: []Route{
+			{
+				Uri:    "/whoami",
+				Method: http.MethodGet,
+				Handler: func(c *gin.Context) {
+					c.String(http.StatusOK, role+":"+strconv.Itoa(os.Getpid()))
+				},
+			},
+		},
+	}
+}
+
+// TestUpgradeHelperProcess is the new binary started by the upgrade tests, not a real test.
+func TestUpgradeHelperProcess(t *testing.T) {
+	if os.Getenv(envUpgradeTestChild) != "1" {
+		t.Skip("helper process for upgrade tests")
+	}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(whoamiHandler("child")); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	if err := server.Run(context.Background()); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func newUpgradableServer(t *testing.T) *TransportServer {
+	t.Helper()
+	t.Setenv(envUpgradeTestChild, "1")
+
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+		WithUpgradeCommand(os.Args[0], "-test.run=^TestUpgradeHelperProcess$"),
+	)
+	if err := server.RegisterHandlers(whoamiHandler("parent")); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	return server
+}
+
+func whoami(t *testing.T, client *http.Client, addr string) (string, int) {
+	t.Helper()
+
+	resp, err := client.Get("http://" + addr + "/api/v1/whoami")
+	if err != nil {
+		t.Fatalf("expected request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	role, pid, _ := strings.Cut(string(body), ":")
+	n, _ := strconv.Atoi(pid)
+
+	return role, n
+}
+
+func killChild(t *testing.T, pid int) {
+	t.Helper()
+
+	if pid == 0 || pid == os.Getpid() {
+		return
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		t.Errorf("failed to stop child process %d: %v", pid, err)
+	}
+}
+
+func TestTransportServerUpgrade(t *testing.T) {
+	server := newUpgradableServer(t)
+	addr, errChan := startTestServer(t, server)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	if role, _ := whoami(t, client, addr); role != "parent" {
+		t.Fatalf("expected parent to serve before upgrade, got %q", role)
+	}
+
+	var failures atomic.Int32
+	stopLoad := make(chan struct{})
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		for {
+			select {
+			case <-stopLoad:
+				return
+			default:
+			}
+			resp, err := client.Get("http://" + addr + "/api/v1/whoami")
+			if err != nil {
+				t.Logf("request failed: %v", err)
+				failures.Add(1)
+				continue
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := server.Upgrade(ctx)
+	close(stopLoad)
+	<-loadDone
+
+	if err != nil {
+		t.Fatalf("expected upgrade to succeed, got %v", err)
+	}
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("old server did not stop after upgrade")
+	}
+
+	role, pid := whoami(t, client, addr)
+	defer killChild(t, pid)
+
+	if role != "child" || pid == os.Getpid() {
+		t.Errorf("expected new process to serve after upgrade, got %s:%d", role, pid)
+	}
+	if n := failures.Load(); n != 0 {
+		t.Errorf("expected no failed requests during upgrade, got %d", n)
+	}
+}
+
+func TestTransportServerUpgradeOnSIGHUP(t *testing.T) {
+	server := newUpgradableServer(t)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run(context.Background())
+	}()
+	<-server.Ready()
+	addr := server.Addr().String()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("expected clean shutdown after upgrade, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after SIGHUP")
+	}
+
+	role, pid := whoami(t, http.DefaultClient, addr)
+	defer killChild(t, pid)
+
+	if role != "child" {
+		t.Errorf("expected new process to serve after upgrade, got %q", role)
+	}
+}
+
+func TestTransportServerUpgradeChildFailure(t *testing.T) {
+	server := NewTransportServer(
+		WithMode(MODE_TEST),
+		WithHost("127.0.0.1"),
+		WithPort(0),
+		WithUpgradeCommand("/bin/false"),
+	)
+	if err := server.RegisterHandlers(whoamiHandler("parent")); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	addr, errChan := startTestServer(t, server)
+	defer stopServer(t, server, errChan)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Upgrade(ctx); !errors.Is(err, ErrUpgradeChildExited) {
+		t.Errorf("expected ErrUpgradeChildExited, got %v", err)
+	}
+
+	if role, _ := whoami(t, http.DefaultClient, addr); role != "parent" {
+		t.Errorf("expected parent to keep serving after failed upgrade, got %q", role)
+	}
+}
+
+func TestTransportServerUpgradeNotListening(t *testing.T) {
+	server := NewTransportServer(WithMode(MODE_TEST))
+
+	if err := server.Upgrade(context.Background()); !errors.Is(err, ErrNotListening) {
+		t.Errorf("expected ErrNotListening, got %v", err)
+	}
+}
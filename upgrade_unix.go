@@ -0,0 +1,24 @@
+//go:build unix
+
+package http
+
+import (
+	"net"
+	"syscall"
+)
+
+func restoreNonblock(l net.Listener) {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	_ = raw.Control(func(fd uintptr) {
+		_ = syscall.SetNonblock(int(fd), true)
+	})
+}
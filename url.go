@@ -0,0 +1,70 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrUnknownRouteName = errors.New("unknown route name")
+	ErrMissingParam     = errors.New("missing route parameter")
+	ErrInvalidParams    = errors.New("route parameters must be name/value pairs")
+)
+
+// URL builds the path of the route registered under name, e.g.
+// URL("user", "id", "42") returns /api/v1/users/42. Values are escaped; a *wildcard
+// value keeps its slashes.
+func (s *TransportServer) URL(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", ErrInvalidParams
+	}
+
+	s.routesMu.Lock()
+	pattern, ok := s.names[name]
+	s.routesMu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRouteName, name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		wildcard := strings.IndexAny(segment, ":*")
+		if wildcard < 0 {
+			continue
+		}
+
+		param := segment[wildcard+1:]
+		value, ok := values[param]
+
+		// A catch-all may be empty, a :param may not.
+		catchAll := segment[wildcard] == '*'
+		if !ok || (value == "" && !catchAll) {
+			return "", fmt.Errorf("route %s: %w: %s", name, ErrMissingParam, param)
+		}
+
+		if catchAll {
+			segments[i] = segment[:wildcard] + escapeWildcard(value)
+		} else {
+			segments[i] = segment[:wildcard] + url.PathEscape(value)
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+func escapeWildcard(value string) string {
+	parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
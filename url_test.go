@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransportServerURL(t *testing.T) {
+	noop := func(c *gin.Context) {}
+
+	server := NewTransportServer(WithMode(MODE_TEST), WithBasePath("/public"))
+	err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{
+			{Name: "users", Uri: "/users", Method: http.MethodGet, Handler: noop},
+			{Name: "user-order", Uri: "/users/:id/orders/:order", Method: http.MethodGet, Handler: noop},
+			{Name: "file", Uri: "/files/*path", Method: http.MethodGet, Handler: noop},
+			{Name: "report", Uri: "/reports/:id", Method: http.MethodGet, Handler: noop, Versions: []string{"v2", "v1"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		route       string
+		params      []string
+		expected    string
+		expectedErr error
+	}{
+		{name: "static route", route: "users", expected: "/public/v1/users"},
+		{name: "params", route: "user-order", params: []string{"id", "42", "order", "7"}, expected: "/public/v1/users/42/orders/7"},
+		{name: "escaped param", route: "user-order", params: []string{"id", "a b/c", "order", "7"}, expected: "/public/v1/users/a%20b%2Fc/orders/7"},
+		{name: "wildcard keeps slashes", route: "file", params: []string{"path", "/docs/my report.pdf"}, expected: "/public/v1/files/docs/my%20report.pdf"},
+		{name: "empty wildcard", route: "file", params: []string{"path", ""}, expected: "/public/v1/files/"},
+		{name: "first version", route: "report", params: []string{"id", "1"}, expected: "/public/v2/reports/1"},
+		{name: "missing param", route: "user-order", params: []string{"id", "42"}, expectedErr: ErrMissingParam},
+		{name: "empty param", route: "user-order", params: []string{"id", "", "order", "7"}, expectedErr: ErrMissingParam},
+		{name: "missing wildcard", route: "file", expectedErr: ErrMissingParam},
+		{name: "odd params", route: "user-order", params: []string{"id"}, expectedErr: ErrInvalidParams},
+		{name: "unknown name", route: "orders", expectedErr: ErrUnknownRouteName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := server.URL(tt.route, tt.params...)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected URL to build, got %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("built URL reaches the route", func(t *testing.T) {
+		path, _ := server.URL("user-order", "id", "a b", "order", "7")
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		server.engine.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestTransportServerDuplicateRouteName(t *testing.T) {
+	noop := func(c *gin.Context) {}
+
+	server := NewTransportServer(WithMode(MODE_TEST))
+	if err := server.RegisterHandlers(&mockHandler{
+		routes: []Route{{Name: "users", Uri: "/users", Method: http.MethodGet, Handler: noop}},
+	}); err != nil {
+		t.Fatalf("expected routes to register, got %v", err)
+	}
+
+	err := server.RegisterVersion("v2", &mockHandler{
+		routes: []Route{{Name: "users", Uri: "/users", Method: http.MethodGet, Handler: noop}},
+	})
+	if !errors.Is(err, ErrDuplicateRouteName) {
+		t.Errorf("expected ErrDuplicateRouteName, got %v", err)
+	}
+}